@@ -0,0 +1,177 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdAlias = cli.Command{
+	Name:  "alias",
+	Usage: "manage package short names",
+	Description: `Command alias manages package short names used by get, bin and info,
+aliases of gopmfile take precedence over the ones of user,
+then package name lists fetched by 'gopm update'
+
+gopm alias list
+gopm alias add [name] [import path]
+gopm alias remove [name]`,
+	Action:      runAlias,
+	Subcommands: aliasCommands,
+	Flags: []cli.Flag{
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+var aliasCommands = []cli.Command{
+	{
+		Name:  "list",
+		Usage: "List package short names",
+		Description: `Command list lists aliases of gopmfile and user,
+package name lists are included with '--all, -a' option
+
+gopm alias list
+gopm alias list -a`,
+		Action: runAliasList,
+		Flags: []cli.Flag{
+			cli.BoolFlag{"all, a", "include package name lists", ""},
+			cli.BoolFlag{"verbose, v", "show process details", ""},
+		},
+	},
+	{
+		Name:  "add",
+		Usage: "Add or replace package short name",
+		Description: `Command add adds or replaces alias of user,
+or of gopmfile with '--project, -p' option
+
+gopm alias add cli github.com/codegangsta/cli
+gopm alias add -p cli github.com/urfave/cli`,
+		Action: runAliasAdd,
+		Flags: []cli.Flag{
+			cli.BoolFlag{"project, p", "save alias to gopmfile", ""},
+			cli.BoolFlag{"verbose, v", "show process details", ""},
+		},
+	},
+	{
+		Name:  "remove",
+		Usage: "Remove package short name",
+		Description: `Command remove removes alias of user,
+or of gopmfile with '--project, -p' option
+
+gopm alias remove cli`,
+		Action: runAliasRemove,
+		Flags: []cli.Flag{
+			cli.BoolFlag{"project, p", "remove alias from gopmfile", ""},
+			cli.BoolFlag{"verbose, v", "show process details", ""},
+		},
+	},
+}
+
+func runAlias(ctx *cli.Context) {
+}
+
+func runAliasList(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	aliases := setting.ListAliases(ctx.Bool("all"))
+	fmt.Printf("Package short names (%d):\n", len(aliases))
+	for _, alias := range aliases {
+		source := alias.Source
+		if alias.IsAmbiguous() {
+			source += ", ambiguous"
+		}
+		fmt.Printf("-> %s = %s (%s)\n", alias.Name, strings.Join(alias.ImportPaths, ", "), source)
+	}
+}
+
+func runAliasAdd(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if len(ctx.Args()) != 2 {
+		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 2"))
+		return
+	}
+	name, importPath := ctx.Args().First(), ctx.Args().Get(1)
+	if err := setting.ValidAlias(name, importPath); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if !ctx.Bool("project") {
+		if err := setting.SetUserAlias(name, importPath); err != nil {
+			errors.SetError(err)
+		}
+		return
+	}
+
+	gf, _, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+	gf.SetValue(setting.ALIAS_SECTION, name, importPath)
+	if err = setting.SaveGopmfile(gf, setting.DefaultGopmfile); err != nil {
+		errors.SetError(err)
+		return
+	}
+	log.Info("Alias saved to gopmfile: %s", name)
+}
+
+func runAliasRemove(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if len(ctx.Args()) != 1 {
+		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1"))
+		return
+	}
+	name := ctx.Args().First()
+
+	if !ctx.Bool("project") {
+		if err := setting.DeleteUserAlias(name); err != nil {
+			errors.SetError(err)
+		}
+		return
+	}
+
+	gf, _, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+	if !gf.DeleteKey(setting.ALIAS_SECTION, name) {
+		errors.SetError(fmt.Errorf("no project alias with given short name: %s", name))
+		return
+	}
+	if err = setting.SaveGopmfile(gf, setting.DefaultGopmfile); err != nil {
+		errors.SetError(err)
+		return
+	}
+	log.Info("Alias removed from gopmfile: %s", name)
+}
@@ -40,14 +40,25 @@ gopm bin <import path>@[<tag|commit|branch>:<value>]
 gopm bin <package name>@[<tag|commit|branch>:<value>]
 
 Can only specify one each time, and only works for projects that 
-contain main package`,
+contain main package
+
+Cross-compile with '--os' and '--arch' options, binaries will be built
+into 'dist' directory under given directory
+
+Package downloaded from registry has no version control information,
+so {{.Version}} and {{.Commit}} in ldflags are set by its revision
+and {{.Date}} is empty`,
 	Action: runBin,
 	Flags: []cli.Flag{
 		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.StringFlag{"ldflags", "", "arguments to pass on each go tool link invocation", ""},
+		cli.StringFlag{"gcflags", "", "arguments to pass on each go tool compile invocation", ""},
 		cli.StringFlag{"dir, d", "./", "build binary to given directory", ""},
 		cli.BoolFlag{"update, u", "update package(s) and dependencies if any", ""},
 		cli.BoolFlag{"remote, r", "build with packages in gopm local repository only", ""},
 		cli.BoolFlag{"verbose, v", "show process details", ""},
+		cli.StringFlag{"os", "", "cross-compile for given operating systems, separated by comma", ""},
+		cli.StringFlag{"arch", "", "cross-compile for given architectures, separated by comma", ""},
 	},
 }
 
@@ -56,6 +67,7 @@ func runBin(ctx *cli.Context) {
 		errors.SetError(err)
 		return
 	}
+	setting.DiscoverRootPath = true
 
 	if len(ctx.Args()) != 1 {
 		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1"))
@@ -103,7 +115,7 @@ func runBin(ctx *cli.Context) {
 		}
 	}
 
-	if err := downloadPackages(".", ctx, []*doc.Node{n}); err != nil {
+	if err := downloadPackages(".", ctx, []*doc.Node{n}, make(map[string]*doc.Pkg)); err != nil {
 		errors.SetError(err)
 		return
 	}
@@ -144,33 +156,63 @@ func runBin(ctx *cli.Context) {
 	// 	return
 	// }
 
+	// Gopmfile of the package, not the one where command runs.
+	gf, _, err := parseGopmfile(setting.GopmfilePath(setting.WorkDir))
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	targets, err := parseBuildTargets(ctx, gf)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	cfg, err := loadBuildConfig(ctx, gf, n.InstallPath, nodeVcsInfo(n))
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
 	if err := linkVendors(ctx, n.ImportPath); err != nil {
 		errors.SetError(err)
 		return
 	}
 
+	if len(targets) > 0 {
+		distDir := path.Join(oldWorkDir, setting.DIST)
+		if ctx.IsSet("dir") {
+			distDir = ctx.String("dir")
+			if !filepath.IsAbs(distDir) {
+				distDir = path.Join(oldWorkDir, distDir)
+			}
+			distDir = path.Join(distDir, setting.DIST)
+		}
+
+		if err := buildTargets(targets, cfg, distDir, path.Base(n.ImportPath), n.ImportPath); err != nil {
+			errors.SetError(err)
+			return
+		}
+
+		log.Info("Command executed successfully!")
+		fmt.Println("Binaries have been built into: " + distDir)
+		return
+	}
+
 	log.Info("Installing...")
 
 	cmdArgs := []string{"go", "install"}
 	if ctx.Bool("verbose") {
 		cmdArgs = append(cmdArgs, "-v")
 	}
-	if len(ctx.String("tags")) > 0 {
-		cmdArgs = append(cmdArgs, "-tags")
-		cmdArgs = append(cmdArgs, ctx.String("tags"))
-	}
+	cmdArgs = append(cmdArgs, cfg.Args()...)
 	cmdArgs = append(cmdArgs, n.ImportPath)
-	if err := execCmd(setting.DefaultVendor, setting.WorkDir, cmdArgs...); err != nil {
+	if err := execCmdEnv(setting.DefaultVendor, setting.WorkDir, cfg.Env, cmdArgs...); err != nil {
 		errors.SetError(fmt.Errorf("fail to run program: %v", err))
 		return
 	}
 
-	gf, _, err := parseGopmfile(setting.GOPMFILE)
-	if err != nil {
-		errors.SetError(err)
-		return
-	}
-
 	// Because build command moved binary to root path.
 	binName := path.Base(n.ImportPath)
 	binPath := path.Join(setting.DefaultVendor, "bin", path.Base(n.ImportPath))
@@ -224,3 +266,25 @@ func runBin(ctx *cli.Context) {
 	log.Info("Command executed successfully!")
 	fmt.Println("Binary has been built into: " + movePath)
 }
+
+// nodeVcsInfo returns version information of package by its revision,
+// which is used when downloaded archive has no version control information.
+// Version is the tag if any, otherwise the commit. Date is unknown.
+func nodeVcsInfo(n *doc.Node) *vcsInfo {
+	info := &vcsInfo{Commit: n.Revision}
+	if len(info.Commit) == 0 && n.IsEmptyVal() && setting.LocalNodes != nil {
+		info.Commit = setting.LocalNodes.MustValue(n.RootPath, "value")
+	}
+	switch n.Type {
+	case doc.TAG:
+		info.Version = n.Value
+	case doc.COMMIT:
+		if len(info.Commit) == 0 {
+			info.Commit = n.Value
+		}
+	}
+	if len(info.Version) == 0 {
+		info.Version = info.Commit
+	}
+	return info
+}
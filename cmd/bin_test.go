@@ -0,0 +1,71 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
+	"github.com/gpmgo/gopm/modules/goconfig"
+)
+
+func Test_loadBuildConfig_NodeVcsInfo(t *testing.T) {
+	// Extracted archive has no version control information.
+	tmpDir, err := ioutil.TempDir("", "gopm-bin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gf, err := goconfig.LoadFromData([]byte("[build]\nldflags = -X main.version={{.Version}} -X main.commit={{.Commit}}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(nil, flag.NewFlagSet("bin", flag.ContinueOnError), nil)
+
+	testCases := []struct {
+		desc   string
+		node   *doc.Node
+		expect string
+	}{
+		{
+			"tag",
+			&doc.Node{Pkg: doc.Pkg{Type: doc.TAG, Value: "v1.0"}},
+			"-X main.version=v1.0 -X main.commit=",
+		},
+		{
+			"commit",
+			&doc.Node{Pkg: doc.Pkg{Type: doc.COMMIT, Value: "abc123"}},
+			"-X main.version=abc123 -X main.commit=abc123",
+		},
+		{
+			"branch resolved by registry",
+			&doc.Node{Pkg: doc.Pkg{Type: doc.BRANCH, Value: "master"}, Revision: "def456"},
+			"-X main.version=def456 -X main.commit=def456",
+		},
+	}
+	for _, tc := range testCases {
+		cfg, err := loadBuildConfig(ctx, gf, tmpDir, nodeVcsInfo(tc.node))
+		if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+		} else if cfg.LDFlags != tc.expect {
+			t.Errorf("%s: expect '%s', got '%s'", tc.desc, tc.expect, cfg.LDFlags)
+		}
+	}
+}
@@ -15,13 +15,21 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/gpmgo/gopm/modules/base"
 	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
 	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/goconfig"
 	"github.com/gpmgo/gopm/modules/log"
 	"github.com/gpmgo/gopm/modules/setting"
 )
@@ -32,19 +40,322 @@ var CmdBuild = cli.Command{
 	Description: `Command build links dependencies according to gopmfile,
 and execute 'go build'
 
-gopm build <go build commands>`,
+gopm build <go build commands>
+
+Build tags, ldflags, gcflags and environment variables in '[build]'
+section of gopmfile are merged with command line options.
+
+Cross-compile for multiple platforms with '--os' and '--arch' options,
+or '[build] targets' in gopmfile, binaries will be built into 'dist' directory:
+
+gopm build --os linux,darwin --arch amd64,arm64`,
 	Action: runBuild,
 	Flags: []cli.Flag{
 		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.StringFlag{"ldflags", "", "arguments to pass on each go tool link invocation", ""},
+		cli.StringFlag{"gcflags", "", "arguments to pass on each go tool compile invocation", ""},
 		cli.BoolFlag{"update, u", "update package(s) and dependencies if any", ""},
 		cli.BoolFlag{"remote, r", "build with packages in gopm local repository only", ""},
 		cli.BoolFlag{"verbose, v", "show process details", ""},
 		cli.StringFlag{"o", "output", "specifies the output file name", ""},
+		cli.StringFlag{"os", "", "cross-compile for given operating systems, separated by comma", ""},
+		cli.StringFlag{"arch", "", "cross-compile for given architectures, separated by comma", ""},
 	},
 }
 
+// A buildTarget represents a platform to cross-compile for.
+type buildTarget struct {
+	GOOS   string
+	GOARCH string
+}
+
+func (t buildTarget) String() string {
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// BinName returns name of binary for the target in format 'name_os_arch[.exe]'.
+func (t buildTarget) BinName(name string) string {
+	name += "_" + t.GOOS + "_" + t.GOARCH
+	if t.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Env returns environment variables to build for the target,
+// cgo is disabled when it's not the host platform unless CGO_ENABLED
+// is set in environment or in given build environment variables.
+func (t buildTarget) Env(buildEnv []string) []string {
+	env := []string{"GOOS=" + t.GOOS, "GOARCH=" + t.GOARCH}
+	if len(os.Getenv("CGO_ENABLED")) > 0 {
+		return env
+	}
+	for _, e := range buildEnv {
+		if strings.HasPrefix(e, "CGO_ENABLED=") {
+			return env
+		}
+	}
+
+	cgo := "0"
+	if t.GOOS == runtime.GOOS && t.GOARCH == runtime.GOARCH {
+		cgo = "1"
+	}
+	return append(env, "CGO_ENABLED="+cgo)
+}
+
+func splitList(list string) []string {
+	items := make([]string, 0, 3)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseBuildTargets returns cross-compilation targets specified by
+// '--os' and '--arch' options, or '[build] targets' in gopmfile.
+// It returns nil if none of them is specified.
+func parseBuildTargets(ctx *cli.Context, gf *goconfig.ConfigFile) ([]buildTarget, error) {
+	oses, arches := splitList(ctx.String("os")), splitList(ctx.String("arch"))
+	if len(oses) > 0 || len(arches) > 0 {
+		if len(oses) == 0 {
+			oses = []string{runtime.GOOS}
+		}
+		if len(arches) == 0 {
+			arches = []string{runtime.GOARCH}
+		}
+
+		targets := make([]buildTarget, 0, len(oses)*len(arches))
+		for _, goos := range oses {
+			for _, goarch := range arches {
+				targets = append(targets, buildTarget{goos, goarch})
+			}
+		}
+		return targets, nil
+	}
+
+	var targets []buildTarget
+	for _, target := range strings.Split(gf.MustValue("build", "targets"), "|") {
+		target = strings.TrimSpace(target)
+		if len(target) == 0 {
+			continue
+		}
+		t, err := parseBuildTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// parseBuildTarget parses build target in form of 'os/arch'.
+func parseBuildTarget(spec string) (buildTarget, error) {
+	infos := strings.Split(spec, "/")
+	if len(infos) != 2 || len(infos[0]) == 0 || len(infos[1]) == 0 {
+		return buildTarget{}, fmt.Errorf("invalid build target: %s", spec)
+	}
+	return buildTarget{infos[0], infos[1]}, nil
+}
+
+// buildTargets builds binaries of given name for every target into distDir,
+// it reports result of each target and returns error if any of them failed.
+func buildTargets(targets []buildTarget, cfg *buildConfig, distDir, name string, args ...string) error {
+	os.MkdirAll(distDir, os.ModePerm)
+
+	failed := make([]string, 0, len(targets))
+	for _, t := range targets {
+		log.Info("Building for %s...", t)
+
+		binPath := path.Join(distDir, t.BinName(name))
+		cmdArgs := append([]string{"go", "build", "-o", binPath}, cfg.Args()...)
+		cmdArgs = append(cmdArgs, args...)
+		log.Debug("Args: %v", cmdArgs)
+
+		env := append(append([]string{}, cfg.Env...), t.Env(cfg.Env)...)
+		if err := execCmdEnv(setting.DefaultVendor, setting.WorkDir, env, cmdArgs...); err != nil {
+			log.Error("Fail to build for %s: %v", t, err)
+			failed = append(failed, t.String())
+			continue
+		}
+		fmt.Printf("%s -> %s\n", t, binPath)
+	}
+
+	log.Info("%d target(s) built, %d failed", len(targets)-len(failed), len(failed))
+	if len(failed) > 0 {
+		return fmt.Errorf("fail to build targets: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// A buildConfig represents build settings merged from '[build]' section
+// of gopmfile and command line options.
+type buildConfig struct {
+	Tags    string
+	LDFlags string
+	GCFlags string
+	Env     []string
+}
+
+// Args returns arguments of build settings to pass to go tool.
+func (cfg *buildConfig) Args() []string {
+	var args []string
+	if len(cfg.Tags) > 0 {
+		args = append(args, "-tags", cfg.Tags)
+	}
+	if len(cfg.LDFlags) > 0 {
+		args = append(args, "-ldflags", cfg.LDFlags)
+	}
+	if len(cfg.GCFlags) > 0 {
+		args = append(args, "-gcflags", cfg.GCFlags)
+	}
+	return args
+}
+
+// joinFlags joins non-empty flags with space.
+func joinFlags(flags ...string) string {
+	list := make([]string, 0, len(flags))
+	for _, f := range flags {
+		if f = strings.TrimSpace(f); len(f) > 0 {
+			list = append(list, f)
+		}
+	}
+	return strings.Join(list, " ")
+}
+
+// buildTags returns build tags of gopmfile merged with command line option,
+// tags can be separated by space or comma.
+func buildTags(ctx *cli.Context, gf *goconfig.ConfigFile) string {
+	tags := make([]string, 0, 5)
+	for _, tag := range strings.FieldsFunc(gf.MustValue("build", "tags")+" "+ctx.String("tags"),
+		func(r rune) bool { return r == ' ' || r == ',' }) {
+		if !base.IsSliceContainsStr(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// vcsInfo represents version information of project used in ldflags templates.
+type vcsInfo struct {
+	Version string
+	Commit  string
+	Date    string
+}
+
+// getVcsInfo gets version information from version control system of given directory.
+// Date is the commit time so that builds of same revision are reproducible.
+// It returns nil if directory is not under version control.
+func getVcsInfo(dir string) (*vcsInfo, error) {
+	info := new(vcsInfo)
+
+	var (
+		stdout, stderr string
+		err            error
+	)
+	switch vcs := doc.GetVcsName(dir); vcs {
+	case "git":
+		if stdout, stderr, err = base.ExecCmdDir(dir, "git", "rev-parse", "HEAD"); err != nil {
+			return nil, fmt.Errorf("fail to get commit: %s", stderr)
+		}
+		info.Commit = strings.TrimSpace(stdout)
+
+		if stdout, stderr, err = base.ExecCmdDir(dir, "git", "describe", "--tags", "--always", "--dirty"); err != nil {
+			return nil, fmt.Errorf("fail to get version: %s", stderr)
+		}
+		info.Version = strings.TrimSpace(stdout)
+
+		if stdout, stderr, err = base.ExecCmdDir(dir, "git", "log", "-1", "--format=%ct"); err != nil {
+			return nil, fmt.Errorf("fail to get commit time: %s", stderr)
+		}
+	case "hg":
+		if stdout, stderr, err = base.ExecCmdDir(dir, "hg", "log", "-r", ".", "--template", "{node}"); err != nil {
+			return nil, fmt.Errorf("fail to get commit: %s", stderr)
+		}
+		info.Commit = strings.TrimSpace(stdout)
+
+		if stdout, stderr, err = base.ExecCmdDir(dir, "hg", "log", "-r", ".", "--template", "{latesttag}"); err != nil {
+			return nil, fmt.Errorf("fail to get version: %s", stderr)
+		}
+		info.Version = strings.TrimSpace(stdout)
+
+		if stdout, stderr, err = base.ExecCmdDir(dir, "hg", "log", "-r", ".", "--template", "{date|hgdate}"); err != nil {
+			return nil, fmt.Errorf("fail to get commit time: %s", stderr)
+		}
+		stdout = strings.Fields(stdout + " ")[0]
+	default:
+		return nil, nil
+	}
+
+	sec, err := strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse commit time: %v", err)
+	}
+	info.Date = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+	return info, nil
+}
+
+// loadBuildConfig loads build settings from '[build]' section of gopmfile
+// and merges them with command line options.
+// Variables {{.Version}}, {{.Commit}} and {{.Date}} in ldflags are rendered
+// by version control information of dir, or given fallback information
+// if dir is not under version control.
+func loadBuildConfig(ctx *cli.Context, gf *goconfig.ConfigFile, dir string, fallback ...*vcsInfo) (*buildConfig, error) {
+	cfg := &buildConfig{
+		Tags:    buildTags(ctx, gf),
+		LDFlags: joinFlags(gf.MustValue("build", "ldflags"), ctx.String("ldflags")),
+		GCFlags: joinFlags(gf.MustValue("build", "gcflags"), ctx.String("gcflags")),
+	}
+
+	if strings.Contains(cfg.LDFlags, "{{") {
+		tmpl, err := template.New("ldflags").Parse(cfg.LDFlags)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse ldflags: %v", err)
+		}
+		info, err := getVcsInfo(dir)
+		if err != nil {
+			return nil, err
+		} else if info == nil {
+			if len(fallback) > 0 && fallback[0] != nil {
+				info = fallback[0]
+			} else {
+				log.Warn("No version control found in %s, version information will be empty", dir)
+				info = new(vcsInfo)
+			}
+		}
+		buf := new(bytes.Buffer)
+		if err = tmpl.Execute(buf, info); err != nil {
+			return nil, fmt.Errorf("fail to render ldflags: %v", err)
+		}
+		cfg.LDFlags = buf.String()
+	}
+
+	for _, env := range strings.Split(gf.MustValue("build", "env"), "|") {
+		env = strings.TrimSpace(env)
+		if len(env) == 0 {
+			continue
+		}
+		if !strings.Contains(env, "=") {
+			return nil, fmt.Errorf("invalid build environment variable: %s", env)
+		}
+		cfg.Env = append(cfg.Env, env)
+	}
+	return cfg, nil
+}
+
 func buildBinary(ctx *cli.Context, args ...string) error {
-	_, target, err := parseGopmfile(setting.GOPMFILE)
+	gf, target, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		return err
+	}
+
+	targets, err := parseBuildTargets(ctx, gf)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadBuildConfig(ctx, gf, setting.WorkDir)
 	if err != nil {
 		return err
 	}
@@ -52,10 +363,23 @@ func buildBinary(ctx *cli.Context, args ...string) error {
 	if err := linkVendors(ctx, ""); err != nil {
 		return err
 	}
+	if policy := loadLicensePolicy(gf); policy != nil {
+		pkgs, err := resolveVendorPkgs(ctx, gf, target)
+		if err != nil {
+			return err
+		}
+		if err = policy.enforce(pkgs); err != nil {
+			return err
+		}
+	}
+
+	if len(targets) > 0 {
+		return buildTargets(targets, cfg, path.Join(setting.WorkDir, setting.DIST), path.Base(target), args...)
+	}
 
 	log.Info("Building...")
 
-	cmdArgs := append([]string{"go", "build"})
+	cmdArgs := []string{"go", "build"}
 
 	// Set output binary name
 	cmdArgs = append(cmdArgs, "-o")
@@ -65,16 +389,12 @@ func buildBinary(ctx *cli.Context, args ...string) error {
 		cmdArgs = append(cmdArgs, path.Base(target))
 	}
 
-	if len(ctx.String("tags")) > 0 {
-		cmdArgs = append(cmdArgs, "-tags")
-		cmdArgs = append(cmdArgs, ctx.String("tags"))
-	}
-
+	cmdArgs = append(cmdArgs, cfg.Args()...)
 	cmdArgs = append(cmdArgs, args...)
 
 	log.Debug("Args: %v", cmdArgs)
 
-	if err := execCmd(setting.DefaultVendor, setting.WorkDir, cmdArgs...); err != nil {
+	if err := execCmdEnv(setting.DefaultVendor, setting.WorkDir, cfg.Env, cmdArgs...); err != nil {
 		return fmt.Errorf("fail to build program: %v", err)
 	}
 
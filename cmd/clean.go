@@ -42,9 +42,21 @@ func runClean(ctx *cli.Context) {
 		return
 	}
 
+	// Wait for other gopm processes to finish writing local repository.
+	lock, err := setting.LockRepo(true)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+	defer lock.Unlock()
+
 	os.RemoveAll(path.Join(setting.HomeDir, ".gopm/temp"))
+	os.RemoveAll(setting.HttpCacheDir)
 	if ctx.Bool("all") {
-		os.Remove(path.Join(setting.HomeDir, ".gopm/data/localnodes.list"))
+		if err = setting.ClearLocalNodes(); err != nil {
+			errors.SetError(err)
+			return
+		}
 		os.RemoveAll(setting.InstallRepoPath)
 	}
 }
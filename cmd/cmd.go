@@ -34,6 +34,8 @@ import (
 // setup initializes and checks common environment variables.
 func setup(ctx *cli.Context) (err error) {
 	setting.Debug = ctx.GlobalBool("debug")
+	setting.RefreshMeta = ctx.GlobalBool("refresh-meta")
+	setting.DiscoverRootPath = false
 	log.NonColor = ctx.GlobalBool("noterm")
 	log.Verbose = ctx.Bool("verbose")
 
@@ -59,7 +61,7 @@ func setup(ctx *cli.Context) (err error) {
 		}
 		setting.WorkDir = strings.Replace(setting.WorkDir, "\\", "/", -1)
 	}
-	setting.DefaultGopmfile = path.Join(setting.WorkDir, setting.GOPMFILE)
+	setting.DefaultGopmfile = setting.GopmfilePath(setting.WorkDir)
 	setting.DefaultVendor = path.Join(setting.WorkDir, setting.VENDOR)
 	setting.DefaultVendorSrc = path.Join(setting.DefaultVendor, "src")
 
@@ -110,17 +112,44 @@ func setup(ctx *cli.Context) (err error) {
 	if err = setting.LoadConfig(); err != nil {
 		return err
 	}
+	if err = doc.SetupHTTP(); err != nil {
+		return err
+	}
 
 	setting.PkgNameListFile = path.Join(setting.HomeDir, ".gopm/data/pkgname.list")
 	if err = setting.LoadPkgNameList(); err != nil {
 		return err
 	}
+	setting.AliasesFile = path.Join(setting.HomeDir, ".gopm/data/aliases.list")
+	if err = setting.LoadUserAliases(); err != nil {
+		return err
+	}
 
 	setting.LocalNodesFile = path.Join(setting.HomeDir, ".gopm/data/localnodes.list")
 	if err = setting.LoadLocalNodes(); err != nil {
 		return err
 	}
-	return nil
+
+	setting.MetaCacheFile = path.Join(setting.HomeDir, ".gopm/data/metacache.json")
+	setting.HttpCacheDir = path.Join(setting.HomeDir, ".gopm/cache/http")
+	setting.RootPathsFile = path.Join(setting.HomeDir, ".gopm/data/rootpaths.list")
+	if err = setting.LoadRootPaths(); err != nil {
+		return err
+	}
+
+	// Lint command reports problems of gopmfile itself.
+	if ctx.Command.Name == "lint" {
+		return nil
+	}
+	var gf *goconfig.ConfigFile
+	if base.IsFile(setting.DefaultGopmfile) {
+		if gf, err = setting.LoadGopmfile(setting.DefaultGopmfile); err != nil {
+			return err
+		}
+		warnLintProblems(checkGopmfile(setting.DefaultGopmfile, gf))
+	}
+	setting.LoadProjectAliases(gf)
+	return setting.LoadRootPathRules(gf)
 }
 
 func parseGopmfile(fileName string) (*goconfig.ConfigFile, string, error) {
@@ -141,6 +170,12 @@ func autoLink(oldPath, newPath string) error {
 }
 
 func execCmd(gopath, curPath string, args ...string) error {
+	return execCmdEnv(gopath, curPath, nil, args...)
+}
+
+// execCmdEnv executes command with given extra environment variables,
+// which are appended to the ones of current process.
+func execCmdEnv(gopath, curPath string, env []string, args ...string) error {
 	oldGopath := os.Getenv("GOPATH")
 	log.Info("Setting GOPATH to %s", gopath)
 
@@ -165,6 +200,9 @@ func execCmd(gopath, curPath string, args ...string) error {
 
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Dir = curPath
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -16,9 +16,15 @@ package cmd
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/gpmgo/gopm/modules/base"
 	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
 	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/log"
 	"github.com/gpmgo/gopm/modules/setting"
 )
 
@@ -28,7 +34,14 @@ var CmdConfig = cli.Command{
 	Description: `Command config configures gopm settings
 
 gopm config set proxy http://<username:password>@server:port
-gopm config github [client_id] [client_secret]
+gopm config set no_proxy localhost,.example.com,10.0.0.0/8
+gopm config set timeout [dial_timeout] [request_timeout]
+gopm config set ca [ca_file]
+gopm config set cert [cert_file] <key_file>
+gopm config set github [client_id] [client_secret]
+gopm config set helper [command]
+gopm config set pkgname_list [url,...]
+gopm config get auth [host]
 `,
 	Action:      runConfig,
 	Subcommands: configCommands,
@@ -69,6 +82,45 @@ gopm config set proxy http://<username:password>@server:port
 `,
 		Action: runConfigSetProxy,
 	},
+	{
+		Name:  "no_proxy",
+		Usage: "Change hosts to bypass HTTP proxy",
+		Description: `Command no_proxy changes comma-separated hosts to bypass HTTP proxy,
+domain matches its subdomains, IP address and CIDR are also supported
+
+gopm config set no_proxy localhost,.example.com,10.0.0.0/8
+`,
+		Action: runConfigSetNoProxy,
+	},
+	{
+		Name:  "timeout",
+		Usage: "Change HTTP timeout settings",
+		Description: `Command timeout changes timeouts of dialing and roundtripping HTTP requests
+
+gopm config set timeout 10s 20s
+`,
+		Action: runConfigSetTimeout,
+	},
+	{
+		Name:  "ca",
+		Usage: "Change custom CA bundle setting",
+		Description: `Command ca changes file of CA certificates in PEM format,
+which are trusted in addition to the system ones
+
+gopm config set ca [ca_file]
+`,
+		Action: runConfigSetCA,
+	},
+	{
+		Name:  "cert",
+		Usage: "Change TLS client certificate setting",
+		Description: `Command cert changes TLS client certificate and key files in PEM format,
+key is read from certificate file if key file is omitted
+
+gopm config set cert [cert_file] <key_file>
+`,
+		Action: runConfigSetCert,
+	},
 	{
 		Name:  "github",
 		Usage: "Change GitHub credentials setting",
@@ -78,34 +130,128 @@ gopm config set github [client_id] [client_secret]
 `,
 		Action: runConfigSetGitHub,
 	},
+	{
+		Name:  "helper",
+		Usage: "Change credential helper setting",
+		Description: `Command helper changes command of credential helper,
+which is called with 'get' or 'store' action in protocol of git credential helpers
+
+gopm config set helper [command]
+`,
+		Action: runConfigSetHelper,
+	},
+	{
+		Name:  "pkgname_list",
+		Usage: "Change remote package name list setting",
+		Description: `Command pkgname_list changes comma-separated URLs of package name lists
+fetched by 'gopm update', short names map to import paths in any of them
+
+gopm config set pkgname_list https://example.com/pkgname.list,https://example.org/pkgname.list
+`,
+		Action: runConfigSetPkgNameList,
+	},
 }
 
 func runConfigSet(ctx *cli.Context) {
 
 }
 
+// settingKeys maps setting names to their keys in [settings] section.
+var settingKeys = map[string][]string{
+	"proxy":        {"HTTP_PROXY"},
+	"no_proxy":     {"NO_PROXY"},
+	"timeout":      {"HTTP_DIAL_TIMEOUT", "HTTP_REQUEST_TIMEOUT"},
+	"ca":           {"HTTP_CA_FILE"},
+	"cert":         {"HTTP_CERT_FILE", "HTTP_KEY_FILE"},
+	"helper":       {"CREDENTIAL_HELPER"},
+	"pkgname_list": {"PKGNAME_LIST_URLS"},
+}
+
 func showSettingString(section, key string) {
 	fmt.Printf("%s = %s\n", key, setting.Cfg.MustValue(section, key))
 }
 
+// maskSecret returns masked value of secret, so it is not printed to terminal.
+func maskSecret(secret string) string {
+	if len(secret) == 0 {
+		return ""
+	}
+	return "********"
+}
+
+func showSecretString(section, key string) {
+	fmt.Printf("%s = %s\n", key, maskSecret(setting.Cfg.MustValue(section, key)))
+}
+
+// maskURL returns URL with password in user information masked,
+// value that cannot be parsed as URL is masked entirely.
+func maskURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return maskSecret(rawURL)
+	}
+	if u.User == nil {
+		return rawURL
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		return rawURL
+	}
+	// Mask is set after encoding, so it is not escaped.
+	userinfo := u.User.String() + "@"
+	masked := url.User(u.User.Username()).String() + ":" + maskSecret(password) + "@"
+	return strings.Replace(u.String(), userinfo, masked, 1)
+}
+
+func showURLString(section, key string) {
+	fmt.Printf("%s = %s\n", key, maskURL(setting.Cfg.MustValue(section, key)))
+}
+
 func runConfigGet(ctx *cli.Context) {
 	if err := setup(ctx); err != nil {
 		errors.SetError(err)
 		return
 	}
 
-	if len(ctx.Args()) != 1 {
+	if len(ctx.Args()) < 1 {
 		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1"))
 		return
 	}
-	switch ctx.Args().First() {
-	case "proxy":
-		fmt.Printf("[%s]\n", "settings")
-		showSettingString("settings", "HTTP_PROXY")
+	switch name := ctx.Args().First(); name {
 	case "github":
 		fmt.Printf("[%s]\n", "github")
 		showSettingString("github", "CLIENT_ID")
-		showSettingString("github", "CLIENT_SECRET")
+		showSecretString("github", "CLIENT_SECRET")
+	case "auth":
+		if len(ctx.Args()) != 2 {
+			errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 2"))
+			return
+		}
+		section := "auth." + ctx.Args().Get(1)
+		fmt.Printf("[%s]\n", section)
+		for _, key := range setting.Cfg.GetKeyList(section) {
+			switch key {
+			case "password", "token":
+				showSecretString(section, key)
+			default:
+				showSettingString(section, key)
+			}
+		}
+	default:
+		keys, ok := settingKeys[name]
+		if !ok {
+			errors.SetError(fmt.Errorf("Unknown setting: %s", name))
+			return
+		}
+		fmt.Printf("[%s]\n", "settings")
+		for _, key := range keys {
+			if key == "HTTP_PROXY" {
+				// Proxy URL may contain password.
+				showURLString("settings", key)
+				continue
+			}
+			showSettingString("settings", key)
+		}
 	}
 }
 
@@ -120,37 +266,99 @@ func runConfigUnset(ctx *cli.Context) {
 		return
 	}
 
-	var err error
-	switch ctx.Args().First() {
-	case "proxy":
-		err = setting.DeleteConfigOption("settings", "HTTP_PROXY")
-	case "github":
-		if err = setting.DeleteConfigOption("github", "CLIENT_ID"); err != nil {
+	section, keys := "settings", settingKeys[ctx.Args().First()]
+	if ctx.Args().First() == "github" {
+		section, keys = "github", []string{"CLIENT_ID", "CLIENT_SECRET"}
+	}
+	if keys == nil {
+		errors.SetError(fmt.Errorf("Unknown setting: %s", ctx.Args().First()))
+		return
+	}
+	for _, key := range keys {
+		if err := setting.DeleteConfigOption(section, key); err != nil {
 			errors.SetError(err)
 			return
 		}
-		err = setting.DeleteConfigOption("github", "CLIENT_SECRET")
-	}
-	if err != nil {
-		errors.SetError(err)
-		return
 	}
 }
 
-func runConfigSetProxy(ctx *cli.Context) {
+// setSettings validates arguments and sets them as values of given keys
+// in [settings] section in order.
+func setSettings(ctx *cli.Context, validate func(args cli.Args) error, keys ...string) {
 	if err := setup(ctx); err != nil {
 		errors.SetError(err)
 		return
 	}
 
-	if len(ctx.Args()) != 1 {
-		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1"))
+	if len(ctx.Args()) != len(keys) {
+		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have %d", len(keys)))
 		return
 	}
-	if err := setting.SetConfigValue("settings", "HTTP_PROXY", ctx.Args().First()); err != nil {
-		errors.SetError(err)
+	if validate != nil {
+		if err := validate(ctx.Args()); err != nil {
+			errors.SetError(err)
+			return
+		}
+	}
+	for i, key := range keys {
+		if err := setting.SetConfigValue("settings", key, ctx.Args().Get(i)); err != nil {
+			errors.SetError(err)
+			return
+		}
+	}
+}
+
+// validFiles returns error if any of given files does not exist.
+func validFiles(args cli.Args) error {
+	for _, name := range args {
+		if !base.IsFile(name) {
+			return fmt.Errorf("File does not exist: %s", name)
+		}
+	}
+	return nil
+}
+
+func runConfigSetProxy(ctx *cli.Context) {
+	setSettings(ctx, func(args cli.Args) error {
+		if _, err := url.Parse(args.First()); err != nil {
+			return fmt.Errorf("Invalid proxy URL: %v", err)
+		}
+		return nil
+	}, "HTTP_PROXY")
+}
+
+func runConfigSetNoProxy(ctx *cli.Context) {
+	setSettings(ctx, nil, "NO_PROXY")
+}
+
+func runConfigSetTimeout(ctx *cli.Context) {
+	setSettings(ctx, func(args cli.Args) error {
+		for _, arg := range args {
+			if _, err := time.ParseDuration(arg); err != nil {
+				return fmt.Errorf("Invalid timeout: %v", err)
+			}
+		}
+		return nil
+	}, "HTTP_DIAL_TIMEOUT", "HTTP_REQUEST_TIMEOUT")
+}
+
+func runConfigSetCA(ctx *cli.Context) {
+	setSettings(ctx, validFiles, "HTTP_CA_FILE")
+}
+
+func runConfigSetCert(ctx *cli.Context) {
+	if len(ctx.Args()) == 1 {
+		setSettings(ctx, validFiles, "HTTP_CERT_FILE")
+		if setting.RuntimeError.HasError {
+			return
+		}
+		// Key is in certificate file.
+		if err := setting.DeleteConfigOption("settings", "HTTP_KEY_FILE"); err != nil {
+			errors.SetError(err)
+		}
 		return
 	}
+	setSettings(ctx, validFiles, "HTTP_CERT_FILE", "HTTP_KEY_FILE")
 }
 
 func runConfigSetGitHub(ctx *cli.Context) {
@@ -167,8 +375,43 @@ func runConfigSetGitHub(ctx *cli.Context) {
 		errors.SetError(err)
 		return
 	}
+
+	// Store secret by credential helper if possible, it is kept apart from
+	// credential of github.com so it is never sent as authentication of requests.
+	if len(doc.CredentialHelper("github.com")) > 0 {
+		if err := doc.StoreOAuthCredential("github.com", &doc.Credential{
+			Username: ctx.Args().First(),
+			Password: ctx.Args().Get(1),
+		}); err != nil {
+			errors.SetError(err)
+			return
+		}
+		if err := setting.DeleteConfigOption("github", "CLIENT_SECRET"); err != nil {
+			errors.SetError(err)
+			return
+		}
+		log.Info("Client secret is stored by credential helper")
+		return
+	}
+
+	log.Warn("Client secret is saved in plain text, use 'gopm config set helper' to store it safely")
 	if err := setting.SetConfigValue("github", "CLIENT_SECRET", ctx.Args().Get(1)); err != nil {
 		errors.SetError(err)
 		return
 	}
 }
+
+func runConfigSetHelper(ctx *cli.Context) {
+	setSettings(ctx, nil, "CREDENTIAL_HELPER")
+}
+
+func runConfigSetPkgNameList(ctx *cli.Context) {
+	setSettings(ctx, func(args cli.Args) error {
+		for _, u := range strings.Split(args.First(), ",") {
+			if _, err := url.ParseRequestURI(strings.TrimSpace(u)); err != nil {
+				return fmt.Errorf("Invalid package name list URL: %v", err)
+			}
+		}
+		return nil
+	}, "PKGNAME_LIST_URLS")
+}
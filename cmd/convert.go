@@ -0,0 +1,106 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdConvert = cli.Command{
+	Name:  "convert",
+	Usage: "convert gopmfile between INI, TOML and JSON formats",
+	Description: `Command convert converts gopmfile to another format,
+format is detected by file name: '.toml', '.json' or INI otherwise
+
+gopm convert gopm.toml
+gopm convert gopm.json .gopmfile
+
+Without source, gopmfile of current project is converted.`,
+	Action: runConvert,
+	Flags: []cli.Flag{
+		cli.BoolFlag{"remove", "remove source gopmfile after conversion", ""},
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+func runConvert(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	var srcPath, destPath string
+	switch len(ctx.Args()) {
+	case 1:
+		srcPath, destPath = setting.DefaultGopmfile, ctx.Args().First()
+	case 2:
+		srcPath, destPath = ctx.Args().First(), ctx.Args().Get(1)
+	default:
+		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1 or 2"))
+		return
+	}
+	if !filepath.IsAbs(destPath) {
+		destPath = path.Join(setting.WorkDir, destPath)
+	}
+	if !filepath.IsAbs(srcPath) {
+		srcPath = path.Join(setting.WorkDir, srcPath)
+	}
+
+	if !base.IsFile(srcPath) {
+		errors.SetError(fmt.Errorf("gopmfile does not exist: %s", srcPath))
+		return
+	} else if srcPath == destPath {
+		errors.SetError(fmt.Errorf("source and destination are the same file: %s", srcPath))
+		return
+	}
+
+	gf, err := setting.LoadGopmfile(srcPath)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if setting.GopmfileFormat(destPath) == setting.FORMAT_INI &&
+		setting.GopmfileFormat(srcPath) == setting.FORMAT_INI {
+		errors.SetError(fmt.Errorf("source and destination are both in INI format"))
+		return
+	}
+	if err = setting.SaveGopmfile(gf, destPath); err != nil {
+		errors.SetError(err)
+		return
+	}
+	log.Info("Converted %s to %s", path.Base(srcPath), path.Base(destPath))
+
+	if ctx.Bool("remove") {
+		if err = os.Remove(srcPath); err != nil {
+			errors.SetError(fmt.Errorf("fail to remove source gopmfile: %v", err))
+			return
+		}
+	} else if path.Dir(srcPath) == path.Dir(destPath) {
+		log.Warn("Source gopmfile is kept and takes precedence in order of %s, %s and %s",
+			setting.GOPMFILE, setting.GOPMFILE_TOML, setting.GOPMFILE_JSON)
+	}
+
+	log.Info("Command executed successfully!")
+}
@@ -0,0 +1,313 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cae"
+	"github.com/gpmgo/gopm/modules/cae/tz"
+	"github.com/gpmgo/gopm/modules/cae/zip"
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdDist = cli.Command{
+	Name:  "dist",
+	Usage: "build and package binary with resources for release",
+	Description: `Command dist links dependencies, builds binary and packages it
+with resources in '[res] include', LICENSE and README files
+
+gopm dist
+gopm dist --os linux,darwin,windows --arch amd64 --format zip,tar.gz
+
+Archives and checksums file are generated in 'dist' directory,
+same sources always produce same archives.`,
+	Action: runDist,
+	Flags: []cli.Flag{
+		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.StringFlag{"ldflags", "", "arguments to pass on each go tool link invocation", ""},
+		cli.StringFlag{"gcflags", "", "arguments to pass on each go tool compile invocation", ""},
+		cli.StringFlag{"os", "", "cross-compile for given operating systems, separated by comma", ""},
+		cli.StringFlag{"arch", "", "cross-compile for given architectures, separated by comma", ""},
+		cli.StringFlag{"format, f", "zip,tar.gz", "archive formats, separated by comma", ""},
+		cli.StringFlag{"dir", setting.DIST, "package archives to given directory", ""},
+		cli.BoolFlag{"remote, r", "build with packages in gopm local repository only", ""},
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+// A distEntry represents a file or directory to be packed into release archive.
+type distEntry struct {
+	Name    string // Relative path in archive.
+	AbsPath string
+	IsDir   bool
+	Mode    os.FileMode
+}
+
+// distFileInfo implements os.FileInfo with normalized information,
+// so archives do not depend on state of local file system.
+type distFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *distFileInfo) Name() string       { return fi.name }
+func (fi *distFileInfo) Size() int64        { return fi.size }
+func (fi *distFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *distFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *distFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *distFileInfo) Sys() interface{}   { return nil }
+
+// collectDistEntries collects binary, resources, license and readme files
+// to be packed, sorted by name in archive.
+func collectDistEntries(binPath, binName string, includes []string) ([]*distEntry, error) {
+	entries := []*distEntry{{binName, binPath, false, 0755}}
+
+	for _, include := range includes {
+		include = strings.TrimSpace(include)
+		if !base.IsDir(include) {
+			continue
+		}
+		err := filepath.Walk(include, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			} else if cae.IsFilter(fi.Name()) {
+				return nil
+			}
+			entries = append(entries, &distEntry{
+				Name:    filepath.ToSlash(p),
+				AbsPath: p,
+				IsDir:   fi.IsDir(),
+				Mode:    cae.NormMode(fi.Mode()),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fail to collect resources(%s): %v", include, err)
+		}
+	}
+
+	fis, err := ioutil.ReadDir(setting.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		name := strings.ToUpper(fi.Name())
+		if fi.IsDir() || !(strings.HasPrefix(name, "LICENSE") ||
+			strings.HasPrefix(name, "COPYING") || strings.HasPrefix(name, "README")) {
+			continue
+		}
+		entries = append(entries, &distEntry{fi.Name(), path.Join(setting.WorkDir, fi.Name()), false, 0644})
+	}
+
+	sort.Sort(distEntries(entries))
+	return entries, nil
+}
+
+type distEntries []*distEntry
+
+func (s distEntries) Len() int           { return len(s) }
+func (s distEntries) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s distEntries) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// distModTime returns modification time for all entries in archives.
+// It respects SOURCE_DATE_EPOCH, otherwise uses commit time of project,
+// or falls back to the earliest time zip format supports.
+func distModTime() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); len(epoch) > 0 {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+		log.Warn("Invalid SOURCE_DATE_EPOCH: %s", epoch)
+	}
+
+	if info, err := getVcsInfo(setting.WorkDir); err == nil && len(info.Date) > 0 {
+		if t, err := time.Parse(time.RFC3339, info.Date); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// packDist packs entries under prefix directory into archive of given format.
+func packDist(format string, entries []*distEntry, prefix string, modTime time.Time, destPath string) error {
+	fw, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+
+	var s cae.Streamer
+	switch format {
+	case "zip":
+		s = zip.NewStreamArachive(fw, zip.PackOptions{Deterministic: true, ModTime: modTime})
+	case "tar.gz":
+		s = tz.NewStreamArachive(fw)
+	default:
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+
+	if err = s.StreamFile(prefix, &distFileInfo{path.Base(prefix), 0, os.ModeDir | 0755, modTime}, nil); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := path.Join(prefix, e.Name)
+		if e.IsDir {
+			err = s.StreamFile(name, &distFileInfo{path.Base(name), 0, e.Mode, modTime}, nil)
+		} else {
+			var data []byte
+			if data, err = ioutil.ReadFile(e.AbsPath); err != nil {
+				return err
+			}
+			err = s.StreamFile(path.Dir(name), &distFileInfo{path.Base(name), int64(len(data)), e.Mode, modTime}, data)
+		}
+		if err != nil {
+			return fmt.Errorf("fail to pack %s: %v", e.Name, err)
+		}
+	}
+	if err = s.Close(); err != nil {
+		return err
+	}
+	return fw.Close()
+}
+
+// writeChecksums writes SHA256 checksums of given archives to distDir.
+func writeChecksums(distDir string, names []string) error {
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(path.Join(distDir, name))
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("fail to compute checksum(%s): %v", name, err)
+		}
+		lines = append(lines, hex.EncodeToString(h.Sum(nil))+"  "+name+"\n")
+	}
+	return ioutil.WriteFile(path.Join(distDir, setting.CHECKSUMS), []byte(strings.Join(lines, "")), 0644)
+}
+
+func runDist(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	formats := splitList(ctx.String("format"))
+	for _, format := range formats {
+		if format != "zip" && format != "tar.gz" {
+			errors.SetError(fmt.Errorf("Unsupported archive format: %s", format))
+			return
+		}
+	}
+
+	gf, target, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	targets, err := parseBuildTargets(ctx, gf)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+	if len(targets) == 0 {
+		targets = []buildTarget{{runtime.GOOS, runtime.GOARCH}}
+	}
+
+	cfg, err := loadBuildConfig(ctx, gf, setting.WorkDir)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if err := linkVendors(ctx, ""); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	tmpDir := base.GetTempDir()
+	defer os.RemoveAll(tmpDir)
+
+	name := path.Base(target)
+	if err := buildTargets(targets, cfg, tmpDir, name); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	distDir := ctx.String("dir")
+	if !filepath.IsAbs(distDir) {
+		distDir = path.Join(setting.WorkDir, distDir)
+	}
+	os.MkdirAll(distDir, os.ModePerm)
+
+	includes := strings.Split(gf.MustValue("res", "include"), "|")
+	modTime := distModTime()
+	archives := make([]string, 0, len(targets)*len(formats))
+	for _, t := range targets {
+		binName := name
+		if t.GOOS == "windows" {
+			binName += ".exe"
+		}
+		entries, err := collectDistEntries(path.Join(tmpDir, t.BinName(name)), binName, includes)
+		if err != nil {
+			errors.SetError(err)
+			return
+		}
+
+		prefix := strings.TrimSuffix(t.BinName(name), ".exe")
+		for _, format := range formats {
+			archive := prefix + "." + format
+			log.Info("Packaging %s...", archive)
+
+			if err = packDist(format, entries, prefix, modTime, path.Join(distDir, archive)); err != nil {
+				errors.SetError(fmt.Errorf("fail to package %s: %v", archive, err))
+				return
+			}
+			archives = append(archives, archive)
+		}
+	}
+
+	if err := writeChecksums(distDir, archives); err != nil {
+		errors.SetError(fmt.Errorf("fail to write checksums: %v", err))
+		return
+	}
+
+	log.Info("Command executed successfully!")
+	fmt.Println("Archives have been packaged into: " + distDir)
+}
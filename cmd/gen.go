@@ -48,7 +48,7 @@ func runGen(ctx *cli.Context) {
 		return
 	}
 
-	gfPath := path.Join(setting.WorkDir, setting.GOPMFILE)
+	gfPath := setting.DefaultGopmfile
 	if !setting.HasGOPATHSetting && !base.IsFile(gfPath) {
 		log.Warn("Dependency list may contain package itself without GOPATH setting and gopmfile.")
 	}
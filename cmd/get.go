@@ -17,7 +17,7 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path"
+	"sort"
 	"strings"
 
 	"github.com/gpmgo/gopm/modules/base"
@@ -98,7 +98,6 @@ func downloadPackage(ctx *cli.Context, n *doc.Node) (*doc.Node, []string, error)
 			if err = n.DownloadGopm(ctx); err != nil {
 				errors.AppendError(errors.NewErrDownload(n.ImportPath + ": " + err.Error()))
 				failCount++
-				os.RemoveAll(n.InstallPath)
 				return nil, nil, nil
 			}
 		}
@@ -120,7 +119,8 @@ func downloadPackage(ctx *cli.Context, n *doc.Node) (*doc.Node, []string, error)
 // downloadPackages downloads packages with certain commit,
 // if the commit is empty string, then it downloads all dependencies,
 // otherwise, it only downloada package with specific commit only.
-func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err error) {
+// Every resolved package is recorded in resolved by root path and version.
+func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node, resolved map[string]*doc.Pkg) (err error) {
 	for _, n := range nodes {
 		// Check if it is a valid remote path or C.
 		if n.ImportPath == "C" {
@@ -139,6 +139,7 @@ func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err e
 		if isSubpackage(n.RootPath, target) {
 			continue
 		}
+		resolved[n.RootPath+n.ValSuffix()] = &n.Pkg
 
 		// Indicates whether need to download package or update.
 		if n.IsFixed() && n.IsExist() {
@@ -171,7 +172,7 @@ func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err e
 				}
 				continue
 			} else {
-				setting.LocalNodes.SetValue(n.RootPath, "value", "")
+				setting.SetLocalNode(n.RootPath, "")
 			}
 		}
 		// Download package.
@@ -181,7 +182,7 @@ func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err e
 		}
 		for _, name := range imports {
 			var gf *goconfig.ConfigFile
-			gfPath := path.Join(n.InstallPath, setting.GOPMFILE)
+			gfPath := setting.GopmfilePath(n.InstallPath)
 
 			// Check if has gopmfile.
 			if base.IsFile(gfPath) {
@@ -211,7 +212,7 @@ func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err e
 					}
 				}
 			}
-			if err = downloadPackages(target, ctx, nodes); err != nil {
+			if err = downloadPackages(target, ctx, nodes, resolved); err != nil {
 				return err
 			}
 		}
@@ -227,7 +228,7 @@ func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err e
 
 		// Only save non-commit node.
 		if nod.IsEmptyVal() && len(nod.Revision) > 0 {
-			setting.LocalNodes.SetValue(nod.RootPath, "value", nod.Revision)
+			setting.SetLocalNode(nod.RootPath, nod.Revision)
 		}
 
 		// If update set downloadPackage will use VSC tools to download the package,
@@ -243,7 +244,8 @@ func downloadPackages(target string, ctx *cli.Context, nodes []*doc.Node) (err e
 }
 
 func getPackages(target string, ctx *cli.Context, nodes []*doc.Node) error {
-	if err := downloadPackages(target, ctx, nodes); err != nil {
+	resolved := make(map[string]*doc.Pkg)
+	if err := downloadPackages(target, ctx, nodes, resolved); err != nil {
 		return err
 	}
 	if err := setting.SaveLocalNodes(); err != nil {
@@ -254,12 +256,27 @@ func getPackages(target string, ctx *cli.Context, nodes []*doc.Node) error {
 	if ctx.GlobalBool("strict") && failCount > 0 && !setting.LibraryMode {
 		return fmt.Errorf("fail to download some packages")
 	}
+
+	gf, _, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		return err
+	}
+	if policy := loadLicensePolicy(gf); policy != nil {
+		pkgs := make([]*doc.Pkg, 0, len(resolved))
+		for _, pkg := range resolved {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Slice(pkgs, func(i, j int) bool {
+			return pkgs[i].RootPath < pkgs[j].RootPath
+		})
+		return policy.enforce(pkgs)
+	}
 	return nil
 }
 
 func getByGopmfile(ctx *cli.Context) error {
 	// Make sure gopmfile exists and up-to-date.
-	gf, target, err := parseGopmfile(setting.GOPMFILE)
+	gf, target, err := parseGopmfile(setting.DefaultGopmfile)
 	if err != nil {
 		return err
 	}
@@ -321,6 +338,7 @@ func runGet(ctx *cli.Context) {
 		errors.SetError(err)
 		return
 	}
+	setting.DiscoverRootPath = true
 
 	// Check option conflicts.
 	hasConflict := false
@@ -358,7 +376,7 @@ func runGet(ctx *cli.Context) {
 	}
 
 	if len(ctx.Args()) > 0 && ctx.Bool("save") {
-		gf, _, err := parseGopmfile(setting.GOPMFILE)
+		gf, _, err := parseGopmfile(setting.DefaultGopmfile)
 		if err != nil {
 			errors.SetError(err)
 			return
@@ -371,6 +389,6 @@ func runGet(ctx *cli.Context) {
 				gf.SetValue("deps", info, "")
 			}
 		}
-		setting.SaveGopmfile(gf, setting.GOPMFILE)
+		setting.SaveGopmfile(gf, setting.DefaultGopmfile)
 	}
 }
@@ -0,0 +1,253 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdInfo = cli.Command{
+	Name:  "info",
+	Usage: "show information of a package",
+	Description: `Command info shows information of a package in local repository
+or on remote: cached versions, root path, repository, license, dependencies and imports
+
+gopm info <import path>@[<tag|commit|branch>:<value>]
+gopm info <package name>@[<tag|commit|branch>:<value>]
+
+Without version, latest revision of default branch is inspected if it is cached.`,
+	Action: runInfo,
+	Flags: []cli.Flag{
+		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.BoolFlag{"test, t", "show test imports", ""},
+		cli.BoolFlag{"json, j", "print information in JSON format", ""},
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+// A cachedVersion represents a revision of package in local repository.
+type cachedVersion struct {
+	Value       string `json:"value"`
+	Revision    string `json:"revision,omitempty"`
+	InstallPath string `json:"install_path"`
+}
+
+// A pkgInfo represents information of a package.
+type pkgInfo struct {
+	ImportPath string            `json:"import_path"`
+	RootPath   string            `json:"root_path"`
+	Type       doc.RevisionType  `json:"type"`
+	Value      string            `json:"value,omitempty"`
+	Synopsis   string            `json:"synopsis,omitempty"`
+	Vcs        string            `json:"vcs,omitempty"`
+	RepoURL    string            `json:"repo_url,omitempty"`
+	SourceURL  string            `json:"source_url,omitempty"`
+	Versions   []cachedVersion   `json:"versions"`
+	Licenses   []*doc.License    `json:"licenses,omitempty"`
+	Gopmfile   string            `json:"gopmfile,omitempty"`
+	Deps       map[string]string `json:"deps,omitempty"`
+	Imports    []string          `json:"imports,omitempty"`
+}
+
+// getPkgInfo collects information of given package, details from source
+// code are only available when requested version is cached.
+func getPkgInfo(ctx *cli.Context, n *doc.Node) (*pkgInfo, error) {
+	info := &pkgInfo{
+		ImportPath: n.ImportPath,
+		RootPath:   n.RootPath,
+		Type:       n.Type,
+		Value:      n.Value,
+		Versions:   []cachedVersion{},
+	}
+
+	vcs, repoURL, err := n.DiscoverRepo()
+	if err != nil {
+		log.Warn("Fail to discover repository of %s: %v", n.ImportPath, err)
+	}
+	info.Vcs = vcs
+	info.RepoURL = repoURL
+	info.SourceURL = n.SourceURL()
+
+	versions, err := doc.ListCachedVersions(n.RootPath)
+	if err != nil {
+		return nil, fmt.Errorf("fail to list cached versions: %v", err)
+	}
+	var installPath string
+	for _, v := range versions {
+		info.Versions = append(info.Versions, cachedVersion{v.Value, v.Revision, v.InstallPath})
+		if v.Value == n.Value {
+			installPath = v.InstallPath
+		}
+	}
+	if len(installPath) == 0 {
+		log.Warn("Package is not in local repository: %s", n.VerString())
+		return info, nil
+	}
+
+	pkgDir := installPath + strings.TrimPrefix(n.ImportPath, n.RootPath)
+	if !base.IsDir(pkgDir) {
+		return nil, fmt.Errorf("package not found in local repository: %s", pkgDir)
+	}
+	info.Synopsis = doc.GetSynopsis(pkgDir)
+	if info.Licenses, err = doc.DetectLicenses(installPath); err != nil {
+		return nil, fmt.Errorf("fail to detect licenses: %v", err)
+	}
+
+	gfPath := setting.GopmfilePath(installPath)
+	if base.IsFile(gfPath) {
+		info.Gopmfile = gfPath
+		gf, err := setting.LoadGopmfile(gfPath)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse gopmfile(%s): %v", gfPath, err)
+		}
+		info.Deps = make(map[string]string)
+		for _, name := range gf.GetKeyList("deps") {
+			info.Deps[name] = gf.MustValue("deps", name)
+		}
+	}
+
+	vendor := base.GetTempDir()
+	defer os.RemoveAll(vendor)
+	imports, err := getDepList(ctx, n.ImportPath, installPath, vendor)
+	if err != nil {
+		return nil, fmt.Errorf("fail to list imports(%s): %v", n.ImportPath, err)
+	}
+	info.Imports = make([]string, 0, len(imports))
+	for _, name := range imports {
+		if name != "C" {
+			info.Imports = append(info.Imports, name)
+		}
+	}
+	return info, nil
+}
+
+func printPkgInfo(info *pkgInfo) {
+	fmt.Printf("Package: %s\n", info.ImportPath)
+	fmt.Printf("Root path: %s\n", info.RootPath)
+	if len(info.Value) > 0 {
+		fmt.Printf("Version: %s:%s\n", info.Type, info.Value)
+	}
+	if len(info.Synopsis) > 0 {
+		fmt.Printf("Synopsis: %s\n", info.Synopsis)
+	}
+	if len(info.Vcs) > 0 {
+		fmt.Printf("Repository: %s (%s)\n", info.RepoURL, info.Vcs)
+	}
+	if len(info.SourceURL) > 0 {
+		fmt.Printf("Source: %s\n", info.SourceURL)
+	}
+	for _, license := range info.Licenses {
+		fmt.Printf("License: %s (%s)\n", license.Name, license.File)
+	}
+
+	fmt.Printf("Cached versions (%d):\n", len(info.Versions))
+	for _, v := range info.Versions {
+		val := v.Value
+		if len(val) == 0 {
+			val = "<UTD>"
+			if len(v.Revision) > 0 {
+				val += " @ " + v.Revision
+			}
+		}
+		fmt.Printf("-> %s: %s\n", val, v.InstallPath)
+	}
+
+	if len(info.Gopmfile) > 0 {
+		fmt.Printf("Gopmfile dependencies (%d):\n", len(info.Deps))
+		names := make([]string, 0, len(info.Deps))
+		for name := range info.Deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if val := info.Deps[name]; len(val) > 0 {
+				fmt.Printf("-> %s @ %s\n", name, val)
+			} else {
+				fmt.Printf("-> %s\n", name)
+			}
+		}
+	}
+
+	if info.Imports != nil {
+		fmt.Printf("Imports (%d):\n", len(info.Imports))
+		for _, name := range info.Imports {
+			fmt.Printf("-> %s\n", name)
+		}
+	}
+}
+
+func runInfo(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if len(ctx.Args()) != 1 {
+		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1"))
+		return
+	}
+
+	pkgPath := ctx.Args().First()
+	tp, val := doc.BRANCH, ""
+	if i := strings.Index(pkgPath, "@"); i > -1 {
+		var err error
+		if tp, val, err = validPkgInfo(pkgPath[i+1:]); err != nil {
+			errors.SetError(err)
+			return
+		}
+		pkgPath = pkgPath[:i]
+	}
+	// Check package name.
+	if !strings.Contains(pkgPath, "/") {
+		fullPath, err := setting.GetPkgFullPath(pkgPath)
+		if err != nil {
+			errors.SetError(err)
+			return
+		}
+		pkgPath = fullPath
+	}
+	if !base.IsValidRemotePath(pkgPath) {
+		errors.SetError(fmt.Errorf("invalid package: %s", pkgPath))
+		return
+	}
+
+	info, err := getPkgInfo(ctx, doc.NewNode(pkgPath, tp, val, false))
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if !ctx.Bool("json") {
+		printPkgInfo(info)
+		return
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		errors.SetError(fmt.Errorf("fail to encode JSON: %v", err))
+		return
+	}
+	fmt.Println(string(data))
+}
@@ -16,7 +16,6 @@ package cmd
 
 import (
 	"fmt"
-	"path"
 
 	"github.com/gpmgo/gopm/modules/cli"
 	"github.com/gpmgo/gopm/modules/errors"
@@ -36,6 +35,8 @@ If no argument is supplied, then gopmfile must be present`,
 	Action: runInstall,
 	Flags: []cli.Flag{
 		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.StringFlag{"ldflags", "", "arguments to pass on each go tool link invocation", ""},
+		cli.StringFlag{"gcflags", "", "arguments to pass on each go tool compile invocation", ""},
 		// cli.BoolFlag{"package, p", "only install non-main packages", ""},
 		cli.BoolFlag{"remote, r", "build with packages in gopm local repository only", ""},
 		cli.BoolFlag{"verbose, v", "show process details", ""},
@@ -54,25 +55,28 @@ func runInstall(ctx *cli.Context) {
 	}
 
 	// Get target name.
-	gfPath := path.Join(setting.WorkDir, setting.GOPMFILE)
-	_, target, err := parseGopmfile(gfPath)
+	gfPath := setting.DefaultGopmfile
+	gf, target, err := parseGopmfile(gfPath)
 	if err != nil {
 		errors.SetError(fmt.Errorf("fail to parse gopmfile: %v", err))
 		return
 	}
 
+	cfg, err := loadBuildConfig(ctx, gf, setting.WorkDir)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
 	log.Info("Installing...")
 
 	cmdArgs := []string{"go", "install"}
 	if ctx.Bool("verbose") {
 		cmdArgs = append(cmdArgs, "-v")
 	}
-	if len(ctx.String("tags")) > 0 {
-		cmdArgs = append(cmdArgs, "-tags")
-		cmdArgs = append(cmdArgs, ctx.String("tags"))
-	}
+	cmdArgs = append(cmdArgs, cfg.Args()...)
 	cmdArgs = append(cmdArgs, target)
-	if err := execCmd(setting.DefaultVendor, setting.WorkDir, cmdArgs...); err != nil {
+	if err := execCmdEnv(setting.DefaultVendor, setting.WorkDir, cfg.Env, cmdArgs...); err != nil {
 		errors.SetError(fmt.Errorf("fail to run program: %v", err))
 		return
 	}
@@ -0,0 +1,289 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/goconfig"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdLicenses = cli.Command{
+	Name:  "licenses",
+	Usage: "show licenses of dependencies",
+	Description: `Command licenses detects licenses of dependencies in gopm local repository
+by their license files and checks them against '[policy]' section of gopmfile
+
+gopm licenses
+
+Make sure you run this command in the root path of a go project.`,
+	Action: runLicenses,
+	Flags: []cli.Flag{
+		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.BoolFlag{"test, t", "include test imports", ""},
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+// locatePkg returns directory of package in GOPATH or gopm local repository
+// in the same way as linkVendors does, or empty string if it is not found.
+func locatePkg(pkg *doc.Pkg) string {
+	if pkg.IsEmptyVal() && setting.HasGOPATHSetting {
+		if dir := path.Join(setting.InstallGopath, pkg.RootPath); base.IsExist(dir) {
+			return dir
+		}
+	}
+	if dir := path.Join(setting.InstallRepoPath, pkg.RootPath+pkg.ValSuffix()); base.IsDir(dir) {
+		return dir
+	}
+	return ""
+}
+
+// resolveVendorPkgs returns dependencies of project in order of root path,
+// they must have been linked by linkVendors.
+// Versions are resolved in the same way as linkVendors does.
+func resolveVendorPkgs(ctx *cli.Context, gf *goconfig.ConfigFile, target string) ([]*doc.Pkg, error) {
+	tags := buildTags(ctx, gf)
+	rootPath := doc.GetRootPath(target)
+	imports, err := doc.ListImports(target, rootPath, setting.DefaultVendor, setting.WorkDir, tags, ctx.Bool("test"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to list imports: %v", err)
+	}
+
+	// Versions of dependencies are specified by gopmfile of their importers.
+	type dep struct {
+		name string
+		gf   *goconfig.ConfigFile
+	}
+	queue := make([]dep, 0, len(imports))
+	for _, name := range imports {
+		queue = append(queue, dep{name, gf})
+	}
+
+	visited := make(map[string]bool)
+	pkgs := make([]*doc.Pkg, 0, len(imports))
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		name := doc.GetRootPath(d.name)
+		if d.name == "C" || visited[name] {
+			continue
+		}
+		visited[name] = true
+
+		tp, val, err := validPkgInfo(d.gf.MustValue("deps", name))
+		if err != nil {
+			return nil, fmt.Errorf("fail to validate package(%s): %v", name, err)
+		}
+		pkg := doc.NewPkg(name, tp, val)
+		pkgs = append(pkgs, pkg)
+
+		// Dependencies of package cannot be listed if it is not found,
+		// detectPkgLicenses reports it.
+		dir := locatePkg(pkg)
+		if len(dir) == 0 {
+			continue
+		}
+		depGf, _, err := parseGopmfile(setting.GopmfilePath(dir))
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse gopmfile(%s): %v", dir, err)
+		}
+		imports, err := doc.ListImports(pkg.RootPath, pkg.RootPath, setting.DefaultVendor, dir, tags, ctx.Bool("test"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range imports {
+			queue = append(queue, dep{name, depGf})
+		}
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].RootPath < pkgs[j].RootPath
+	})
+	return pkgs, nil
+}
+
+// A pkgLicense represents licenses of a package
+// and why it violates license policy if it does.
+type pkgLicense struct {
+	Pkg       *doc.Pkg
+	Licenses  []*doc.License
+	Violation string
+}
+
+// Names returns names of licenses, or LICENSE_NONE if package has no license file.
+func (l *pkgLicense) Names() []string {
+	if len(l.Licenses) == 0 {
+		return []string{doc.LICENSE_NONE}
+	}
+	names := make([]string, 0, len(l.Licenses))
+	for _, license := range l.Licenses {
+		if !base.IsSliceContainsStr(names, license.Name) {
+			names = append(names, license.Name)
+		}
+	}
+	return names
+}
+
+// detectPkgLicenses detects licenses of given packages in GOPATH or local repository,
+// and checks them against policy if it is not nil.
+// Packages cannot be found are treated as having no license file.
+func detectPkgLicenses(pkgs []*doc.Pkg, policy *licensePolicy) ([]*pkgLicense, error) {
+	licenses := make([]*pkgLicense, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		l := &pkgLicense{Pkg: pkg}
+		if dir := locatePkg(pkg); len(dir) > 0 {
+			list, err := doc.DetectLicenses(dir)
+			if err != nil {
+				return nil, fmt.Errorf("fail to detect licenses(%s): %v", pkg.RootPath, err)
+			}
+			l.Licenses = list
+		} else {
+			log.Warn("Package not found in GOPATH or local repository: %s%s", pkg.RootPath, pkg.VerSuffix())
+		}
+		if policy != nil {
+			l.Violation = policy.check(l.Names())
+		}
+		licenses = append(licenses, l)
+	}
+	return licenses, nil
+}
+
+// A licensePolicy represents licenses of dependencies allowed or denied
+// by '[policy] allow_licenses' and 'deny_licenses' in gopmfile.
+type licensePolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// loadLicensePolicy returns license policy of gopmfile, or nil if there is none.
+func loadLicensePolicy(gf *goconfig.ConfigFile) *licensePolicy {
+	p := &licensePolicy{
+		Allow: splitList(gf.MustValue("policy", "allow_licenses")),
+		Deny:  splitList(gf.MustValue("policy", "deny_licenses")),
+	}
+	if len(p.Allow) == 0 && len(p.Deny) == 0 {
+		return nil
+	}
+	return p
+}
+
+// containsFold returns true if list contains given string, case insensitive.
+func containsFold(list []string, str string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, str) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns reason of violation if any of licenses is denied,
+// or none of them is allowed when allowed licenses are set.
+// It returns empty string if licenses conform to policy.
+func (p *licensePolicy) check(names []string) string {
+	for _, name := range names {
+		if containsFold(p.Deny, name) {
+			return "license " + name + " is denied"
+		}
+	}
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	for _, name := range names {
+		if containsFold(p.Allow, name) {
+			return ""
+		}
+	}
+	return "license " + strings.Join(names, ", ") + " is not allowed"
+}
+
+// enforce returns error if any of given packages violates policy.
+func (p *licensePolicy) enforce(pkgs []*doc.Pkg) error {
+	licenses, err := detectPkgLicenses(pkgs, p)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, l := range licenses {
+		if len(l.Violation) > 0 {
+			log.Error("License policy violation: %s%s: %s", l.Pkg.RootPath, l.Pkg.VerSuffix(), l.Violation)
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%d package(s) violate license policy, run 'gopm licenses' for details", count)
+	}
+	return nil
+}
+
+func runLicenses(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	gf, target, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+	if err = linkVendors(ctx, ""); err != nil {
+		errors.SetError(err)
+		return
+	}
+	pkgs, err := resolveVendorPkgs(ctx, gf, target)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	policy := loadLicensePolicy(gf)
+	licenses, err := detectPkgLicenses(pkgs, policy)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	count := 0
+	fmt.Printf("Licenses of dependencies (%d):\n", len(licenses))
+	for _, l := range licenses {
+		files := make([]string, 0, len(l.Licenses))
+		for _, license := range l.Licenses {
+			files = append(files, license.File)
+		}
+		line := fmt.Sprintf("-> %s%s: %s", l.Pkg.RootPath, l.Pkg.VerSuffix(), strings.Join(l.Names(), ", "))
+		if len(files) > 0 {
+			line += " (" + strings.Join(files, ", ") + ")"
+		}
+		if len(l.Violation) > 0 {
+			line += " [" + l.Violation + "]"
+			count++
+		}
+		fmt.Println(line)
+	}
+	if count > 0 {
+		errors.SetError(fmt.Errorf("%d package(s) violate license policy", count))
+	}
+}
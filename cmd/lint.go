@@ -0,0 +1,238 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/goconfig"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdLint = cli.Command{
+	Name:  "lint",
+	Usage: "check gopmfile for problems",
+	Description: `Command lint checks sections, keys and values of gopmfile
+and prints all problems with file name and line number
+
+gopm lint
+gopm lint path/to/.gopmfile`,
+	Action: runLint,
+	Flags: []cli.Flag{
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+// gopmfileSchema contains known sections and keys of gopmfile,
+// nil means any key is allowed.
+var gopmfileSchema = map[string][]string{
+	goconfig.DEFAULT_SECTION:  {"include"},
+	"target":                  {"path"},
+	"deps":                    nil,
+	"res":                     {"include"},
+	"project":                 {"local_gopath"},
+	"build":                   {"targets", "tags", "ldflags", "gcflags", "env"},
+	"include":                 nil,
+	setting.ROOT_PATH_SECTION: nil,
+	setting.ALIAS_SECTION:     nil,
+	"policy":                  {"allow_licenses", "deny_licenses"},
+}
+
+// isKnownKey returns true if key is in given list, case sensitive.
+func isKnownKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// A lintProblem represents a problem found in gopmfile.
+type lintProblem struct {
+	FileName string
+	Line     int
+	Message  string
+	IsError  bool // Warnings do not prevent commands from running.
+}
+
+func (p *lintProblem) String() string {
+	if len(p.FileName) == 0 {
+		return p.Message
+	} else if p.Line == 0 {
+		return p.FileName + ": " + p.Message
+	}
+	return fmt.Sprintf("%s:%d: %s", p.FileName, p.Line, p.Message)
+}
+
+type lintProblems []*lintProblem
+
+func (s lintProblems) Len() int { return len(s) }
+func (s lintProblems) Less(i, j int) bool {
+	if s[i].FileName != s[j].FileName {
+		return s[i].FileName < s[j].FileName
+	}
+	return s[i].Line < s[j].Line
+}
+func (s lintProblems) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// HasError returns true if any of problems is an error.
+func (s lintProblems) HasError() bool {
+	for _, p := range s {
+		if p.IsError {
+			return true
+		}
+	}
+	return false
+}
+
+// lintGopmfile parses given gopmfile with positions and checks it by
+// checkGopmfile, parse error is reported as the only problem.
+func lintGopmfile(fileName string) lintProblems {
+	var gf *goconfig.ConfigFile
+	var err error
+	if setting.GopmfileFormat(fileName) == setting.FORMAT_INI {
+		gf, err = goconfig.LoadConfigFile(fileName)
+	} else {
+		gf, err = setting.LoadGopmfile(fileName)
+	}
+	if err != nil {
+		// Parse error message already contains position.
+		return lintProblems{{Message: err.Error(), IsError: true}}
+	}
+	return checkGopmfile(fileName, gf)
+}
+
+// checkGopmfile checks loaded gopmfile against schema, import paths
+// and revision specs. It returns problems sorted by position.
+func checkGopmfile(fileName string, gf *goconfig.ConfigFile) lintProblems {
+	problems := make(lintProblems, 0, 5)
+	add := func(section, key string, isError bool, format string, args ...interface{}) {
+		name, line := gf.Position(section, key)
+		if len(name) == 0 {
+			name = fileName
+		}
+		problems = append(problems, &lintProblem{name, line, fmt.Sprintf(format, args...), isError})
+	}
+
+	for _, section := range gf.GetSectionList() {
+		keys, ok := gopmfileSchema[section]
+		if !ok {
+			add(section, "", false, "unknown section '%s'", section)
+			continue
+		}
+
+		for _, key := range gf.GetKeyList(section) {
+			if keys != nil && !isKnownKey(keys, key) {
+				add(section, key, false, "unknown key '%s' in section '%s'", key, section)
+				continue
+			}
+			val, err := gf.GetValue(section, key)
+			if err != nil {
+				add(section, key, true, "%v", err)
+				continue
+			}
+
+			switch section {
+			case "deps":
+				if !base.IsValidRemotePath(key) {
+					add(section, key, true, "invalid import path '%s'", key)
+				}
+				if _, _, err = validPkgInfo(val); err != nil {
+					add(section, key, true, "invalid revision of '%s': %v", key, err)
+				}
+			case "target":
+				if len(val) == 0 {
+					add(section, key, true, "empty target path")
+				}
+			case setting.ROOT_PATH_SECTION:
+				if _, err = setting.ParseRootPathRule(key, val); err != nil {
+					add(section, key, true, "%v", err)
+				}
+			case "policy":
+				for _, name := range splitList(val) {
+					if !doc.IsKnownLicense(name) {
+						add(section, key, false, "unknown license '%s'", name)
+					}
+				}
+			case setting.ALIAS_SECTION:
+				if err = setting.ValidAlias(key, val); err != nil {
+					add(section, key, true, "%v", err)
+				}
+			case "build":
+				if key != "targets" {
+					break
+				}
+				for _, spec := range strings.Split(val, "|") {
+					if spec = strings.TrimSpace(spec); len(spec) == 0 {
+						continue
+					}
+					if _, err = parseBuildTarget(spec); err != nil {
+						add(section, key, true, "%v", err)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Sort(problems)
+	return problems
+}
+
+// warnLintProblems prints problems of gopmfile as warnings,
+// so commands still run and 'gopm lint' fails on errors.
+func warnLintProblems(problems lintProblems) {
+	for _, p := range problems {
+		log.Warn("%s", p)
+	}
+	if len(problems) > 0 {
+		log.Warn("gopmfile has %d problem(s), run 'gopm lint' for details", len(problems))
+	}
+}
+
+func runLint(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	fileName := setting.DefaultGopmfile
+	if ctx.Args().Present() {
+		fileName = ctx.Args().First()
+	}
+	if !base.IsFile(fileName) {
+		errors.SetError(fmt.Errorf("gopmfile does not exist: %s", fileName))
+		return
+	}
+
+	problems := lintGopmfile(fileName)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if problems.HasError() || (ctx.GlobalBool("strict") && len(problems) > 0) {
+		errors.SetError(fmt.Errorf("%d problem(s) found in %s", len(problems), fileName))
+		return
+	}
+
+	log.Info("Command executed successfully!")
+	fmt.Println("No problem found in " + fileName)
+}
@@ -40,6 +40,8 @@ you need to run the command right at the local_gopath dir.`,
 	Action: runRun,
 	Flags: []cli.Flag{
 		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.StringFlag{"ldflags", "", "arguments to pass on each go tool link invocation", ""},
+		cli.StringFlag{"gcflags", "", "arguments to pass on each go tool compile invocation", ""},
 		cli.BoolFlag{"local, l", "run command with local gopath context", ""},
 		cli.BoolFlag{"verbose, v", "show process details", ""},
 	},
@@ -74,7 +76,8 @@ func validPkgInfo(info string) (doc.RevisionType, string, error) {
 }
 
 func linkVendors(ctx *cli.Context, optTarget string) error {
-	gfPath := path.Join(setting.WorkDir, setting.GOPMFILE)
+	// Work directory is changed by bin command, so path is resolved here.
+	gfPath := setting.GopmfilePath(setting.WorkDir)
 	gf, target, err := parseGopmfile(gfPath)
 	if err != nil {
 		return fmt.Errorf("fail to parse gopmfile: %v", err)
@@ -83,6 +86,7 @@ func linkVendors(ctx *cli.Context, optTarget string) error {
 		target = optTarget
 	}
 	rootPath := doc.GetRootPath(target)
+	tags := buildTags(ctx, gf)
 
 	// TODO: local support.
 
@@ -99,7 +103,7 @@ func linkVendors(ctx *cli.Context, optTarget string) error {
 
 	// Check and loads dependency packages.
 	log.Debug("Loading dependencies...")
-	imports, err := doc.ListImports(target, rootPath, setting.DefaultVendor, setting.WorkDir, ctx.String("tags"), ctx.Bool("test"))
+	imports, err := doc.ListImports(target, rootPath, setting.DefaultVendor, setting.WorkDir, tags, ctx.Bool("test"))
 	if err != nil {
 		return fmt.Errorf("fail to list imports: %v", err)
 	}
@@ -148,14 +152,14 @@ func linkVendors(ctx *cli.Context, optTarget string) error {
 		}
 		stack = stack[:lastIdx]
 
-		gf, target, err := parseGopmfile(path.Join(linkPath, setting.GOPMFILE))
+		gf, target, err := parseGopmfile(setting.GopmfilePath(linkPath))
 		if err != nil {
 			return fmt.Errorf("fail to parse gopmfile(%s): %v", linkPath, err)
 		}
 		// parseGopmfile only returns right target when parse work directory.
 		target = pkg.RootPath
 		rootPath := target
-		imports, err := doc.ListImports(target, rootPath, setting.DefaultVendor, linkPath, ctx.String("tags"), ctx.Bool("test"))
+		imports, err := doc.ListImports(target, rootPath, setting.DefaultVendor, linkPath, tags, ctx.Bool("test"))
 		if err != nil {
 			errors.SetError(err)
 		}
@@ -183,6 +187,18 @@ func runRun(ctx *cli.Context) {
 		return
 	}
 
+	gf, _, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		errors.SetError(fmt.Errorf("fail to parse gopmfile: %v", err))
+		return
+	}
+
+	cfg, err := loadBuildConfig(ctx, gf, setting.WorkDir)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
 	if err := linkVendors(ctx, ""); err != nil {
 		errors.SetError(err)
 		return
@@ -191,12 +207,9 @@ func runRun(ctx *cli.Context) {
 	log.Info("Running...")
 
 	cmdArgs := []string{"go", "run"}
-	if len(ctx.String("tags")) > 0 {
-		cmdArgs = append(cmdArgs, "-tags")
-		cmdArgs = append(cmdArgs, ctx.String("tags"))
-	}
+	cmdArgs = append(cmdArgs, cfg.Args()...)
 	cmdArgs = append(cmdArgs, ctx.Args()...)
-	if err := execCmd(setting.DefaultVendor, setting.WorkDir, cmdArgs...); err != nil {
+	if err := execCmdEnv(setting.DefaultVendor, setting.WorkDir, cfg.Env, cmdArgs...); err != nil {
 		errors.SetError(fmt.Errorf("fail to run program: %v", err))
 		return
 	}
@@ -0,0 +1,83 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+// writeTestFiles writes files with given names and contents under dir.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		name = path.Join(dir, name)
+		os.MkdirAll(path.Dir(name), os.ModePerm)
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test_linkVendors_WorkDir checks that dependencies are linked by gopmfile
+// of current work directory, which is changed to the package by bin command.
+func Test_linkVendors_WorkDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gopm-cmd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		// Project where command runs.
+		"caller/.gopmfile": "[deps]\ngithub.com/foo/dep = commit:caller\n",
+		// Package to be built, as bin command links it.
+		"caller/vendor/pkg/.gopmfile":        "[target]\npath = github.com/foo/pkg\n\n[deps]\ngithub.com/foo/dep = commit:pkg\n",
+		"caller/vendor/pkg/main.go":          "package main\n\nimport _ \"github.com/foo/dep\"\n\nfunc main() {}\n",
+		"repo/github.com/foo/dep.pkg/dep.go": "package dep\n",
+	})
+
+	oldWorkDir, oldGopmfile := setting.WorkDir, setting.DefaultGopmfile
+	oldVendor, oldVendorSrc, oldRepo := setting.DefaultVendor, setting.DefaultVendorSrc, setting.InstallRepoPath
+	defer func() {
+		setting.WorkDir, setting.DefaultGopmfile = oldWorkDir, oldGopmfile
+		setting.DefaultVendor, setting.DefaultVendorSrc, setting.InstallRepoPath = oldVendor, oldVendorSrc, oldRepo
+	}()
+	oldGo111Module := os.Getenv("GO111MODULE")
+	os.Setenv("GO111MODULE", "off")
+	defer os.Setenv("GO111MODULE", oldGo111Module)
+
+	// Set as setup does in caller, then change work directory as bin command does.
+	setting.WorkDir = path.Join(tmpDir, "caller")
+	setting.DefaultGopmfile = setting.GopmfilePath(setting.WorkDir)
+	setting.DefaultVendor = path.Join(setting.WorkDir, setting.VENDOR)
+	setting.DefaultVendorSrc = path.Join(setting.DefaultVendor, "src")
+	setting.InstallRepoPath = path.Join(tmpDir, "repo")
+	setting.WorkDir = path.Join(setting.WorkDir, "vendor/pkg")
+
+	ctx := cli.NewContext(nil, flag.NewFlagSet("bin", flag.ContinueOnError), nil)
+	if err = linkVendors(ctx, "github.com/foo/pkg"); err != nil {
+		t.Fatal(err)
+	}
+	if !base.IsFile(path.Join(setting.DefaultVendorSrc, "github.com/foo/dep/dep.go")) {
+		t.Error("dependency of package is not linked")
+	}
+}
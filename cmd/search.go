@@ -0,0 +1,148 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
+	"github.com/gpmgo/gopm/modules/errors"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+var CmdSearch = cli.Command{
+	Name:  "search",
+	Usage: "search for package by keyword",
+	Description: `Command search searches packages by keyword in package short names,
+packages in local repository and optionally on registry
+
+gopm search <keyword>
+gopm search -r <keyword>`,
+	Action: runSearch,
+	Flags: []cli.Flag{
+		cli.BoolFlag{"remote, r", "search on registry as well", ""},
+		cli.BoolFlag{"verbose, v", "show process details", ""},
+	},
+}
+
+// A searchResult represents a matched package from any source.
+type searchResult struct {
+	ImportPath string
+	ShortName  string
+	Synopsis   string
+	IsCached   bool // True if package is in local repository.
+}
+
+// matchKeyword returns true if keyword is in any of given fields, case insensitive.
+func matchKeyword(keyword string, fields ...string) bool {
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func runSearch(ctx *cli.Context) {
+	if err := setup(ctx); err != nil {
+		errors.SetError(err)
+		return
+	}
+
+	if len(ctx.Args()) != 1 {
+		errors.SetError(fmt.Errorf("Incorrect number of arguments for command: should have 1"))
+		return
+	}
+	keyword := strings.ToLower(ctx.Args().First())
+
+	results := make(map[string]*searchResult)
+	get := func(importPath string) *searchResult {
+		r, ok := results[importPath]
+		if !ok {
+			r = &searchResult{ImportPath: importPath}
+			results[importPath] = r
+		}
+		return r
+	}
+
+	// Short names of aliases and package name lists.
+	shortNames := make(map[string]string)
+	for _, alias := range setting.ListAliases(true) {
+		for _, importPath := range alias.ImportPaths {
+			shortNames[importPath] = alias.Name
+			if matchKeyword(keyword, alias.Name, importPath) {
+				get(importPath).ShortName = alias.Name
+			}
+		}
+	}
+
+	// Packages in local repository.
+	nodes, err := doc.ListCachedPackages()
+	if err != nil {
+		log.Warn("Fail to list packages in local repository: %v", err)
+	}
+	for _, n := range nodes {
+		if !matchKeyword(keyword, n.ImportPath, n.Synopsis, shortNames[n.ImportPath]) {
+			continue
+		}
+		r := get(n.ImportPath)
+		r.ShortName = shortNames[n.ImportPath]
+		if len(n.Synopsis) > 0 {
+			r.Synopsis = n.Synopsis
+		}
+		r.IsCached = true
+	}
+
+	// Registry.
+	if ctx.Bool("remote") {
+		nodes, err = doc.SearchRegistry(keyword)
+		if err != nil {
+			log.Warn("Fail to search on registry: %v", err)
+		}
+		for _, n := range nodes {
+			r := get(n.ImportPath)
+			r.ShortName = shortNames[n.ImportPath]
+			if len(r.Synopsis) == 0 {
+				r.Synopsis = n.Synopsis
+			}
+		}
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Search results (%d):\n", len(names))
+	for _, name := range names {
+		r := results[name]
+		line := "-> " + r.ImportPath
+		if len(r.ShortName) > 0 {
+			line += " (" + r.ShortName + ")"
+		}
+		if r.IsCached {
+			line += " [cached]"
+		}
+		fmt.Println(line)
+		if len(r.Synopsis) > 0 {
+			fmt.Println("   " + r.Synopsis)
+		}
+	}
+}
@@ -33,6 +33,8 @@ gopm test <go test commands>`,
 	Action: runTest,
 	Flags: []cli.Flag{
 		cli.StringFlag{"tags", "", "apply build tags", ""},
+		cli.StringFlag{"ldflags", "", "arguments to pass on each go tool link invocation", ""},
+		cli.StringFlag{"gcflags", "", "arguments to pass on each go tool compile invocation", ""},
 		cli.BoolFlag{"verbose, v", "show process details", ""},
 	},
 }
@@ -43,6 +45,18 @@ func runTest(ctx *cli.Context) {
 		return
 	}
 
+	gf, _, err := parseGopmfile(setting.DefaultGopmfile)
+	if err != nil {
+		errors.SetError(fmt.Errorf("fail to parse gopmfile: %v", err))
+		return
+	}
+
+	cfg, err := loadBuildConfig(ctx, gf, setting.WorkDir)
+	if err != nil {
+		errors.SetError(err)
+		return
+	}
+
 	if err := linkVendors(ctx, ""); err != nil {
 		errors.SetError(err)
 		return
@@ -51,15 +65,12 @@ func runTest(ctx *cli.Context) {
 	log.Info("Testing...")
 
 	cmdArgs := []string{"go", "test"}
-	if len(ctx.String("tags")) > 0 {
-		cmdArgs = append(cmdArgs, "-tags")
-		cmdArgs = append(cmdArgs, ctx.String("tags"))
-	}
+	cmdArgs = append(cmdArgs, cfg.Args()...)
 	if ctx.IsSet("verbose") {
 		cmdArgs = append(cmdArgs, "-v")
 	}
 	cmdArgs = append(cmdArgs, ctx.Args()...)
-	if err := execCmd(setting.DefaultVendor, setting.WorkDir, cmdArgs...); err != nil {
+	if err := execCmdEnv(setting.DefaultVendor, setting.WorkDir, cfg.Env, cmdArgs...); err != nil {
 		errors.SetError(fmt.Errorf("fail to run program: %v", err))
 		return
 	}
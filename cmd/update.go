@@ -15,9 +15,9 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
@@ -85,6 +85,24 @@ func execPath() (string, error) {
 	return p, nil
 }
 
+// fetchPkgNameLists fetches package name lists from given URLs and joins them
+// in order, conflicts between lists are reported when short names are used.
+func fetchPkgNameLists(urls []string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, u := range urls {
+		data, err := base.HttpGetBytes(doc.HttpClient, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", u, err)
+		}
+		fmt.Fprintf(buf, "# %s\n", u)
+		buf.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
 func runUpdate(ctx *cli.Context) {
 	if setting.LibraryMode {
 		errors.SetError(fmt.Errorf("Library mode does not support update command"))
@@ -104,15 +122,16 @@ func runUpdate(ctx *cli.Context) {
 		log.Fatal("Fail to fetch VERSION.json: %v", err)
 	}
 
-	// Package name list.
-	if remoteVerInfo.PackageNameList > localVerInfo.PackageNameList {
+	// Package name list, custom lists have no version so always be updated.
+	if remoteVerInfo.PackageNameList > localVerInfo.PackageNameList ||
+		len(setting.Cfg.MustValue("settings", "PKGNAME_LIST_URLS")) > 0 {
 		log.Info("Updating pkgname.list...%v > %v",
 			localVerInfo.PackageNameList, remoteVerInfo.PackageNameList)
-		data, err := base.HttpGetBytes(doc.HttpClient, "https://raw.githubusercontent.com/gpmgo/docs/master/pkgname.list", nil)
+		data, err := fetchPkgNameLists(setting.PkgNameListURLs)
 		if err != nil {
 			log.Warn("Fail to update pkgname.list: %v", err)
 		} else {
-			if err = ioutil.WriteFile(setting.PkgNameListFile, data, os.ModePerm); err != nil {
+			if err = base.WriteFileAtomic(setting.PkgNameListFile, data); err != nil {
 				log.Fatal("Fail to save pkgname.list: %v", err)
 			}
 			log.Info("Update pkgname.list to %v succeed!", remoteVerInfo.PackageNameList)
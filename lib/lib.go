@@ -23,6 +23,7 @@ import (
 
 	"github.com/gpmgo/gopm/cmd"
 	"github.com/gpmgo/gopm/modules/cli"
+	"github.com/gpmgo/gopm/modules/doc"
 	"github.com/gpmgo/gopm/modules/log"
 	"github.com/gpmgo/gopm/modules/setting"
 )
@@ -42,23 +43,31 @@ func Run(args []string) *setting.Error {
 	app.Commands = []cli.Command{
 		cmd.CmdList,
 		cmd.CmdGen,
+		cmd.CmdLint,
+		cmd.CmdConvert,
 		cmd.CmdGet,
 		cmd.CmdBin,
 		cmd.CmdConfig,
 		cmd.CmdRun,
 		cmd.CmdTest,
 		cmd.CmdBuild,
+		cmd.CmdDist,
 		cmd.CmdInstall,
 		cmd.CmdClean,
 		cmd.CmdUpdate,
-		// CmdSearch,
+		cmd.CmdSearch,
+		cmd.CmdInfo,
+		cmd.CmdAlias,
+		cmd.CmdLicenses,
 	}
 	app.Flags = append(app.Flags, []cli.Flag{
 		cli.BoolFlag{"noterm, n", "disable color output", ""},
 		cli.BoolFlag{"strict, s", "strict mode", ""},
 		cli.BoolFlag{"debug, d", "debug mode", ""},
+		cli.BoolFlag{"refresh-meta", "ignore cached go-import meta tags and failed lookups", ""},
 	}...)
 	app.Run(args)
+	doc.LogHttpCacheStats()
 	return setting.RuntimeError
 }
 
@@ -0,0 +1,77 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package base
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"unsafe"
+)
+
+const _LOCKFILE_EXCLUSIVE_LOCK = 0x2
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+// A FileLock represents an advisory lock on a file shared across processes.
+type FileLock struct {
+	f *os.File
+}
+
+// LockFile acquires an exclusive advisory lock on given file,
+// the file is created if it does not exist.
+// It blocks until the lock is acquired.
+func LockFile(name string) (*FileLock, error) {
+	return lockFile(name, true)
+}
+
+// RLockFile acquires a shared advisory lock on given file,
+// multiple processes can hold shared lock at the same time
+// but not with an exclusive lock.
+func RLockFile(name string) (*FileLock, error) {
+	return lockFile(name, false)
+}
+
+func lockFile(name string, exclusive bool) (*FileLock, error) {
+	os.MkdirAll(path.Dir(name), os.ModePerm)
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags uintptr
+	if exclusive {
+		flags = _LOCKFILE_EXCLUSIVE_LOCK
+	}
+	ol := new(syscall.Overlapped)
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0,
+		1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		f.Close()
+		return nil, err
+	}
+	return &FileLock{f}, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	ol := new(syscall.Overlapped)
+	procUnlockFileEx.Call(l.f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	return l.f.Close()
+}
@@ -25,6 +25,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -757,6 +758,35 @@ func (a argInt) Get(i int, args ...int) (r int) {
 	return
 }
 
+// WriteFileAtomic writes data to a temporary file in the same directory,
+// flushes it to disk and renames it to given name,
+// so readers never see a partially written file.
+func WriteFileAtomic(name string, data []byte) error {
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	} else if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	} else if err = f.Close(); err != nil {
+		return err
+	} else if err = os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
 //GetTempDir generates and returns time-based unique temporary path.
 func GetTempDir() string {
 	return path.Join(os.TempDir(), ToStr(time.Now().Nanosecond()))
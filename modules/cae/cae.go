@@ -16,11 +16,159 @@
 package cae
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"strings"
 )
 
+// Limits of extraction to protect from archive bombs,
+// zero or negative value means no limit.
+var (
+	MaxExtractSize  int64 = 1 << 30 // Maximum total uncompressed size in bytes.
+	MaxExtractFiles       = 100000  // Maximum number of entries.
+)
+
+// ErrUnsafeEntry occurs when an archive entry would be extracted
+// outside of destination directory.
+type ErrUnsafeEntry struct {
+	Name   string
+	Reason string
+}
+
+func (err ErrUnsafeEntry) Error() string {
+	return fmt.Sprintf("unsafe archive entry '%s': %s", err.Name, err.Reason)
+}
+
+// ErrExceedLimit occurs when extraction exceeds size or number of files limit.
+type ErrExceedLimit struct {
+	Name  string // Name of the limit.
+	Limit int64
+}
+
+func (err ErrExceedLimit) Error() string {
+	return fmt.Sprintf("archive exceeds limit of %s: %d", err.Name, err.Limit)
+}
+
+// SafeJoin joins entry name to destination directory,
+// it returns ErrUnsafeEntry if the entry is an absolute path
+// or escapes from destination by '..' components.
+func SafeJoin(destPath, name string) (string, error) {
+	name = strings.Replace(name, "\\", "/", -1)
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", ErrUnsafeEntry{name, "absolute path"}
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrUnsafeEntry{name, "path escapes from destination"}
+	}
+	return path.Join(destPath, cleaned), nil
+}
+
+// CheckLink returns ErrUnsafeEntry if symbolic link entry with given target
+// points to outside of destination directory. Target with '..' after other
+// components is also rejected, because it is resolved through links on disk
+// (e.g. 'p/..' with 'p -> .') rather than as cleaned path.
+func CheckLink(name, target string) error {
+	target = strings.Replace(target, "\\", "/", -1)
+	if strings.HasPrefix(target, "/") || (len(target) > 1 && target[1] == ':') {
+		return ErrUnsafeEntry{name, "symbolic link to absolute path " + target}
+	}
+
+	isDescended := false
+	for _, elem := range strings.Split(target, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if isDescended {
+				return ErrUnsafeEntry{name, "symbolic link goes up after other components: " + target}
+			}
+		default:
+			isDescended = true
+		}
+	}
+
+	linkPath := path.Join(path.Dir(path.Clean(strings.Replace(name, "\\", "/", -1))), target)
+	if linkPath == ".." || strings.HasPrefix(linkPath, "../") {
+		return ErrUnsafeEntry{name, "symbolic link escapes from destination: " + target}
+	}
+	return nil
+}
+
+// CheckParents returns ErrUnsafeEntry if any existing parent directory
+// of entry in destination directory is a symbolic link, because chained
+// links that pass CheckLink one by one may still lead to outside of destination.
+func CheckParents(destPath, name string) error {
+	name = path.Clean(strings.Replace(name, "\\", "/", -1))
+	dirs := strings.Split(name, "/")
+	curPath := destPath
+	for i := 0; i < len(dirs)-1; i++ {
+		curPath = path.Join(curPath, dirs[i])
+		fi, err := os.Lstat(curPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return ErrUnsafeEntry{name, "parent directory is a symbolic link: " + strings.Join(dirs[:i+1], "/")}
+		}
+	}
+	return nil
+}
+
+// A Counter tracks number of entries and bytes during extraction
+// to enforce MaxExtractFiles and MaxExtractSize.
+type Counter struct {
+	NumFiles int
+	Size     int64
+}
+
+// AddFile increases number of entries.
+func (c *Counter) AddFile() error {
+	c.NumFiles++
+	if MaxExtractFiles > 0 && c.NumFiles > MaxExtractFiles {
+		return ErrExceedLimit{"number of files", int64(MaxExtractFiles)}
+	}
+	return nil
+}
+
+// Copy copies from r to w and stops when total size exceeds limit,
+// so declared size in archive headers is not trusted.
+func (c *Counter) Copy(w io.Writer, r io.Reader) (int64, error) {
+	if MaxExtractSize <= 0 {
+		n, err := io.Copy(w, r)
+		c.Size += n
+		return n, err
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, MaxExtractSize-c.Size+1))
+	c.Size += n
+	if err != nil {
+		return n, err
+	} else if c.Size > MaxExtractSize {
+		return n, ErrExceedLimit{"uncompressed size", MaxExtractSize}
+	}
+	return n, nil
+}
+
+// NormMode returns normalized permission of given file mode,
+// so archives do not depend on umask and state of local file system.
+func NormMode(mode os.FileMode) os.FileMode {
+	switch {
+	case mode.IsDir():
+		return os.ModeDir | 0755
+	case mode&os.ModeSymlink != 0:
+		return os.ModeSymlink | 0777
+	case mode&0111 != 0:
+		return 0755
+	}
+	return 0644
+}
+
 // A Streamer describes an streamable archive object.
 type Streamer interface {
 	StreamFile(string, os.FileInfo, []byte) error
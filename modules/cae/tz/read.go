@@ -0,0 +1,103 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package tz
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// OpenFile is the generalized open call; most users will use Open
+// instead. It opens the named tar.gz file with specified flag
+// (O_RDONLY etc.) if applicable. If successful,
+// methods on the returned TzArchive can be used for I/O.
+// If there is an error, it will be of type *PathError.
+func (z *TzArchive) Open(name string, flag int, perm os.FileMode) error {
+	// Create a new archive if it's specified and not exist.
+	if flag&os.O_CREATE != 0 {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		gw := gzip.NewWriter(f)
+		tw := tar.NewWriter(gw)
+		if err = tw.Close(); err != nil {
+			return err
+		} else if err = gw.Close(); err != nil {
+			return err
+		} else if err = f.Close(); err != nil {
+			return err
+		}
+	}
+
+	z.FileName = name
+	z.Flag = flag
+	z.Permission = perm
+	z.isHasChanged = false
+	z.files = make([]*File, 0, 10)
+
+	err := walkArchive(name, func(h *tar.Header, r io.Reader) error {
+		h.Name = strings.Replace(h.Name, "\\", "/", -1)
+		if h.FileInfo().IsDir() && !strings.HasSuffix(h.Name, "/") {
+			h.Name += "/"
+		}
+		z.files = append(z.files, &File{Header: h, isOrigin: true})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	z.NumFiles = len(z.files)
+	return nil
+}
+
+// walkArchive calls fn for every entry in the named tar.gz file
+// with its header and content reader.
+// Plain tar file without gzip compression is also accepted.
+func walkArchive(name string, fn func(*tar.Header, io.Reader) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = fn(h, tr); err != nil {
+			return err
+		}
+	}
+}
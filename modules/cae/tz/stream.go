@@ -0,0 +1,81 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package tz
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+)
+
+// A StreamArchive represents a streamable archive.
+type StreamArchive struct {
+	*tar.Writer
+	gw *gzip.Writer
+}
+
+// NewStreamArachive returns a new streamable archive with given io.Writer.
+// It's caller's responsibility to close io.Writer and streamer after operation.
+func NewStreamArachive(w io.Writer) *StreamArchive {
+	gw := gzip.NewWriter(w)
+	return &StreamArchive{tar.NewWriter(gw), gw}
+}
+
+// StreamFile streams a file or directory entry into StreamArchive.
+func (s *StreamArchive) StreamFile(relPath string, fi os.FileInfo, data []byte) error {
+	h, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		h.Name = relPath + "/"
+		return s.Writer.WriteHeader(h)
+	}
+
+	h.Name = path.Join(relPath, fi.Name())
+	h.Size = int64(len(data))
+	if err = s.Writer.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err = s.Writer.Write(data)
+	return err
+}
+
+// StreamReader streams data from io.Reader to StreamArchive.
+func (s *StreamArchive) StreamReader(relPath string, fi os.FileInfo, r io.Reader) (err error) {
+	h, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	h.Name = path.Join(relPath, fi.Name())
+
+	if err = s.Writer.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err = io.Copy(s.Writer, r)
+	return err
+}
+
+// Close finishes writing tar and gzip streams,
+// it does not close underlying io.Writer.
+func (s *StreamArchive) Close() error {
+	if err := s.Writer.Close(); err != nil {
+		return err
+	}
+	return s.gw.Close()
+}
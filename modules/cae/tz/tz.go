@@ -0,0 +1,234 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package tz enables you to transparently read or write TAR.GZ compressed archives and the files inside them.
+// Plain TAR archives can be read as well, changes are always saved with gzip compression.
+package tz
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/cae"
+)
+
+// A File represents a file or directory entry in archive.
+type File struct {
+	*tar.Header
+	absPath  string // Absolute path of local file system.
+	isOrigin bool   // Indicates whether it comes from original archive.
+}
+
+// A TzArchive represents a file archive, compressed with Tar and Gzip.
+type TzArchive struct {
+	FileName   string
+	NumFiles   int
+	Flag       int
+	Permission os.FileMode
+
+	files        []*File
+	isHasChanged bool
+
+	// For supporting flushing to io.Writer.
+	writer      io.Writer
+	isHasWriter bool
+}
+
+// OpenFile is the generalized open call; most users will use Open
+// instead. It opens the named tar.gz file with specified flag
+// (O_RDONLY etc.) if applicable. If successful,
+// methods on the returned TzArchive can be used for I/O.
+// If there is an error, it will be of type *PathError.
+func OpenFile(name string, flag int, perm os.FileMode) (*TzArchive, error) {
+	z := new(TzArchive)
+	err := z.Open(name, flag, perm)
+	return z, err
+}
+
+// Create creates the named tar.gz file, truncating
+// it if it already exists. If successful, methods on the returned
+// TzArchive can be used for I/O; the associated file descriptor has mode
+// O_RDWR.
+// If there is an error, it will be of type *PathError.
+func Create(name string) (*TzArchive, error) {
+	os.MkdirAll(path.Dir(name), os.ModePerm)
+	return OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named tar.gz file for reading. If successful, methods on
+// the returned TzArchive can be used for reading; the associated file
+// descriptor has mode O_RDONLY.
+// If there is an error, it will be of type *PathError.
+func Open(name string) (*TzArchive, error) {
+	return OpenFile(name, os.O_RDONLY, 0)
+}
+
+// New accepts a variable that implemented interface io.Writer
+// for write-only purpose operations.
+func New(w io.Writer) *TzArchive {
+	return &TzArchive{
+		writer:      w,
+		isHasWriter: true,
+	}
+}
+
+// List returns a string slice of files' name in TzArchive.
+// Specify prefixes will be used as filters.
+func (z *TzArchive) List(prefixes ...string) []string {
+	isHasPrefix := len(prefixes) > 0
+	names := make([]string, 0, z.NumFiles)
+	for _, f := range z.files {
+		if isHasPrefix && !cae.HasPrefix(f.Name, prefixes) {
+			continue
+		}
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// AddEmptyDir adds a raw directory entry to TzArchive,
+// it returns false if same directory enry already existed.
+func (z *TzArchive) AddEmptyDir(dirPath string) bool {
+	if !strings.HasSuffix(dirPath, "/") {
+		dirPath += "/"
+	}
+
+	for _, f := range z.files {
+		if dirPath == f.Name {
+			return false
+		}
+	}
+
+	dirPath = strings.TrimSuffix(dirPath, "/")
+	if strings.Contains(dirPath, "/") {
+		// Auto add all upper level directories.
+		z.AddEmptyDir(path.Dir(dirPath))
+	}
+	z.files = append(z.files, &File{
+		Header: &tar.Header{
+			Name:     dirPath + "/",
+			Mode:     int64(os.ModePerm),
+			Typeflag: tar.TypeDir,
+		},
+	})
+	z.updateStat()
+	return true
+}
+
+// AddDir adds a directory and subdirectories entries to TzArchive.
+func (z *TzArchive) AddDir(dirPath, absPath string) error {
+	dir, err := os.Open(absPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	// Make sure we have all upper level directories.
+	z.AddEmptyDir(dirPath)
+
+	fis, err := dir.Readdir(0)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		curPath := strings.Replace(absPath+"/"+fi.Name(), "\\", "/", -1)
+		tmpRecPath := strings.Replace(filepath.Join(dirPath, fi.Name()), "\\", "/", -1)
+		if fi.IsDir() {
+			if err = z.AddDir(tmpRecPath, curPath); err != nil {
+				return err
+			}
+		} else {
+			if err = z.AddFile(tmpRecPath, curPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateStat should be called after every change for rebuilding statistic.
+func (z *TzArchive) updateStat() {
+	z.NumFiles = len(z.files)
+	z.isHasChanged = true
+}
+
+// AddFile adds a file entry to TzArchive.
+func (z *TzArchive) AddFile(fileName, absPath string) error {
+	if cae.IsFilter(absPath) {
+		return nil
+	}
+
+	fi, err := os.Lstat(absPath)
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(absPath); err != nil {
+			return err
+		}
+	}
+
+	file := new(File)
+	file.Header, err = tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	file.Name = fileName
+	file.absPath = absPath
+
+	z.AddEmptyDir(path.Dir(fileName))
+
+	isExist := false
+	for i, f := range z.files {
+		if fileName == f.Name {
+			z.files[i] = file
+			isExist = true
+			break
+		}
+	}
+	if !isExist {
+		z.files = append(z.files, file)
+	}
+
+	z.updateStat()
+	return nil
+}
+
+// DeleteIndex deletes an entry in the archive by its index.
+func (z *TzArchive) DeleteIndex(idx int) error {
+	if idx >= z.NumFiles {
+		return errors.New("index out of range of number of files")
+	}
+
+	z.files = append(z.files[:idx], z.files[idx+1:]...)
+	z.updateStat()
+	return nil
+}
+
+// DeleteName deletes an entry in the archive by its name.
+func (z *TzArchive) DeleteName(name string) error {
+	for i, f := range z.files {
+		if f.Name == name {
+			return z.DeleteIndex(i)
+		}
+	}
+	return errors.New("entry with given name not found")
+}
@@ -0,0 +1,406 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package tz
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/cae"
+)
+
+// Switcher of printing trace information when pack and extract.
+var Verbose = true
+
+// extractFile extracts tar entry to file system.
+func extractFile(h *tar.Header, r io.Reader, destPath string, c *cae.Counter) error {
+	filePath, err := cae.SafeJoin(destPath, h.Name)
+	if err != nil {
+		return err
+	} else if err = cae.CheckParents(destPath, h.Name); err != nil {
+		return err
+	}
+	os.MkdirAll(path.Dir(filePath), os.ModePerm)
+
+	switch h.Typeflag {
+	case tar.TypeSymlink:
+		if err = cae.CheckLink(h.Name, h.Linkname); err != nil {
+			return err
+		}
+		os.Remove(filePath)
+		return os.Symlink(h.Linkname, filePath)
+	case tar.TypeLink:
+		linkPath, err := cae.SafeJoin(destPath, h.Linkname)
+		if err != nil {
+			return cae.ErrUnsafeEntry{Name: h.Name, Reason: "hard link escapes from destination: " + h.Linkname}
+		} else if err = cae.CheckParents(destPath, h.Linkname); err != nil {
+			return err
+		}
+		os.Remove(filePath)
+		return os.Link(linkPath, filePath)
+	case tar.TypeReg, tar.TypeRegA:
+		// Do not write through existing symbolic link.
+		if fi, err := os.Lstat(filePath); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			os.Remove(filePath)
+		}
+	default:
+		// Skip devices, FIFOs and other special files.
+		return nil
+	}
+
+	fw, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+
+	if _, err = c.Copy(fw, r); err != nil {
+		return err
+	}
+
+	// Set back file information.
+	if err = os.Chtimes(filePath, h.ModTime, h.ModTime); err != nil {
+		return err
+	}
+	return os.Chmod(filePath, h.FileInfo().Mode())
+}
+
+var defaultExtractFunc = func(fullName string, fi os.FileInfo) error {
+	if !Verbose {
+		return nil
+	}
+
+	fmt.Println("Extracting file..." + fullName)
+	return nil
+}
+
+// ExtractToFunc extracts the whole archive or the given files to the
+// specified destination.
+// It accepts a function as a middleware for custom operations.
+func (z *TzArchive) ExtractToFunc(destPath string, fn cae.HookFunc, entries ...string) (err error) {
+	if z.isHasWriter {
+		return errors.New("cannot extract write-only archive")
+	}
+
+	destPath = strings.Replace(destPath, "\\", "/", -1)
+	isHasEntry := len(entries) > 0
+	if Verbose {
+		fmt.Println("Untarring " + z.FileName + "...")
+	}
+	os.MkdirAll(destPath, os.ModePerm)
+	c := new(cae.Counter)
+	return walkArchive(z.FileName, func(h *tar.Header, r io.Reader) error {
+		h.Name = strings.Replace(h.Name, "\\", "/", -1)
+		fi := h.FileInfo()
+		if fi.IsDir() && !strings.HasSuffix(h.Name, "/") {
+			h.Name += "/"
+		}
+		if isHasEntry && !cae.IsEntry(h.Name, entries) {
+			return nil
+		}
+		if err := c.AddFile(); err != nil {
+			return err
+		}
+
+		// Directory.
+		if fi.IsDir() {
+			dirPath, err := cae.SafeJoin(destPath, h.Name)
+			if err != nil {
+				return err
+			} else if err = cae.CheckParents(destPath, h.Name); err != nil {
+				return err
+			}
+			if err = fn(h.Name, fi); err != nil {
+				return nil
+			}
+			os.MkdirAll(dirPath, os.ModePerm)
+			return nil
+		}
+
+		// File.
+		if err := fn(h.Name, fi); err != nil {
+			return nil
+		}
+		return extractFile(h, r, destPath, c)
+	})
+}
+
+// ExtractToFunc extracts the whole archive or the given files to the
+// specified destination.
+// It accepts a function as a middleware for custom operations.
+func ExtractToFunc(srcPath, destPath string, fn cae.HookFunc, entries ...string) (err error) {
+	z, err := Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	return z.ExtractToFunc(destPath, fn, entries...)
+}
+
+// ExtractTo extracts the whole archive or the given files to the
+// specified destination.
+// Call Flush() to apply changes before this.
+func (z *TzArchive) ExtractTo(destPath string, entries ...string) (err error) {
+	return z.ExtractToFunc(destPath, defaultExtractFunc, entries...)
+}
+
+// ExtractTo extracts given archive or the given files to the
+// specified destination.
+func ExtractTo(srcPath, destPath string, entries ...string) (err error) {
+	return ExtractToFunc(srcPath, destPath, defaultExtractFunc, entries...)
+}
+
+// writeFile writes a file entry with its content from local file system to tar.Writer.
+func writeFile(tw *tar.Writer, f *File) error {
+	if err := tw.WriteHeader(f.Header); err != nil {
+		return err
+	}
+	if len(f.absPath) == 0 || f.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	fr, err := os.Open(f.absPath)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	_, err = io.Copy(tw, fr)
+	return err
+}
+
+// flushTo writes entries of TzArchive to io.Writer, unchanged entries
+// are copied from original archive and others are read from file system.
+func (z *TzArchive) flushTo(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// Entries come from original archive are copied in original order.
+	isKept := make(map[string]bool, len(z.files))
+	for _, f := range z.files {
+		if f.isOrigin {
+			isKept[f.Name] = true
+		}
+	}
+	if len(isKept) > 0 {
+		err := walkArchive(z.FileName, func(h *tar.Header, r io.Reader) error {
+			name := strings.Replace(h.Name, "\\", "/", -1)
+			if h.FileInfo().IsDir() && !strings.HasSuffix(name, "/") {
+				name += "/"
+			}
+			if !isKept[name] {
+				return nil
+			}
+			if err := tw.WriteHeader(h); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, r)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range z.files {
+		if f.isOrigin {
+			continue
+		}
+		if err := writeFile(tw, f); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Flush saves changes to original tar.gz file if any.
+func (z *TzArchive) Flush() error {
+	if !z.isHasChanged || (len(z.FileName) == 0 && !z.isHasWriter) {
+		return nil
+	}
+
+	if z.isHasWriter {
+		return z.flushTo(z.writer)
+	}
+
+	// Write to a temporary file in the same directory and replace original one.
+	tmp, err := ioutil.TempFile(path.Dir(z.FileName), "."+path.Base(z.FileName))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = z.flushTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	} else if err = tmp.Close(); err != nil {
+		return err
+	} else if err = os.Rename(tmp.Name(), z.FileName); err != nil {
+		return err
+	}
+	return z.Open(z.FileName, os.O_RDWR, z.Permission)
+}
+
+// packFile packs a file or directory to tar.Writer.
+func packFile(srcFile string, recPath string, tw *tar.Writer, fi os.FileInfo) error {
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(srcFile); err != nil {
+			return err
+		}
+	}
+
+	h, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	h.Name = filepath.ToSlash(recPath)
+	if fi.IsDir() {
+		h.Name += "/"
+	}
+
+	return writeFile(tw, &File{Header: h, absPath: srcFile})
+}
+
+// packDir packs a directory and its subdirectories and files
+// recursively to tar.Writer.
+func packDir(srcPath string, recPath string, tw *tar.Writer, fn cae.HookFunc) error {
+	dir, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	fis, err := dir.Readdir(0)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if cae.IsFilter(fi.Name()) {
+			continue
+		}
+		curPath := srcPath + "/" + fi.Name()
+		tmpRecPath := filepath.Join(recPath, fi.Name())
+		if err = fn(curPath, fi); err != nil {
+			continue
+		}
+
+		if fi.IsDir() {
+			if err = packFile(curPath, tmpRecPath, tw, fi); err != nil {
+				return err
+			}
+			err = packDir(curPath, tmpRecPath, tw, fn)
+		} else {
+			err = packFile(curPath, tmpRecPath, tw, fi)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packToWriter packs given path object to io.Writer.
+func packToWriter(srcPath string, w io.Writer, fn cae.HookFunc, includeDir bool) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	fi, err := os.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	basePath := path.Base(srcPath)
+	if fi.IsDir() {
+		if includeDir {
+			if err = packFile(srcPath, basePath, tw, fi); err != nil {
+				return err
+			}
+		} else {
+			basePath = ""
+		}
+		err = packDir(srcPath, basePath, tw, fn)
+	} else {
+		err = packFile(srcPath, basePath, tw, fi)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// packTo packs given source path object to target path.
+func packTo(srcPath, destPath string, fn cae.HookFunc, includeDir bool) error {
+	fw, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+
+	if err = packToWriter(srcPath, fw, fn, includeDir); err != nil {
+		return err
+	}
+	return fw.Close()
+}
+
+// PackToFunc packs the complete archive to the specified destination.
+// It accepts a function as a middleware for custom operations.
+func PackToFunc(srcPath, destPath string, fn func(fullName string, fi os.FileInfo) error, includeDir ...bool) error {
+	isIncludeDir := false
+	if len(includeDir) > 0 && includeDir[0] {
+		isIncludeDir = true
+	}
+	return packTo(srcPath, destPath, fn, isIncludeDir)
+}
+
+var defaultPackFunc = func(fullName string, fi os.FileInfo) error {
+	if !Verbose {
+		return nil
+	}
+
+	if fi.IsDir() {
+		fmt.Printf("Adding dir...%s\n", fullName)
+	} else {
+		fmt.Printf("Adding file...%s\n", fullName)
+	}
+	return nil
+}
+
+// PackTo packs the whole archive to the specified destination.
+// Call Flush() will automatically call this in the end.
+func PackTo(srcPath, destPath string, includeDir ...bool) error {
+	return PackToFunc(srcPath, destPath, defaultPackFunc, includeDir...)
+}
+
+// Close saves changes of archive if any.
+func (z *TzArchive) Close() error {
+	return z.Flush()
+}
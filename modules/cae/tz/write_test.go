@@ -0,0 +1,179 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package tz
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/gpmgo/gopm/modules/cae"
+)
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+// writeTestArchive creates a tar.gz file with given entries.
+func writeTestArchive(t *testing.T, name string, entries []testEntry) {
+	fw, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+
+	gw := gzip.NewWriter(fw)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		h := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     0644,
+			Size:     int64(len(e.body)),
+		}
+		if e.typeflag == tar.TypeDir {
+			h.Mode = 0755
+		}
+		if err = tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	} else if err = gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ExtractTo(t *testing.T) {
+	Verbose = false
+	testCases := []struct {
+		desc     string
+		entries  []testEntry
+		isUnsafe bool
+		files    []string // Files expected to be extracted.
+	}{
+		{
+			"regular files and link inside destination",
+			[]testEntry{
+				{"pkg/", tar.TypeDir, "", ""},
+				{"pkg/main.go", tar.TypeReg, "", "package main"},
+				{"pkg/link.go", tar.TypeSymlink, "main.go", ""},
+				{"pkg/hard.go", tar.TypeLink, "pkg/main.go", ""},
+				{"pkg/sub/up.go", tar.TypeSymlink, "../main.go", ""},
+			},
+			false,
+			[]string{"pkg/main.go", "pkg/link.go", "pkg/hard.go", "pkg/sub/up.go"},
+		},
+		{
+			"relative path escapes",
+			[]testEntry{{"../pwned.txt", tar.TypeReg, "", "pwned"}},
+			true, nil,
+		},
+		{
+			"absolute path",
+			[]testEntry{{"/pwned.txt", tar.TypeReg, "", "pwned"}},
+			true, nil,
+		},
+		{
+			"symbolic link escapes",
+			[]testEntry{{"a/b", tar.TypeSymlink, "../..", ""}},
+			true, nil,
+		},
+		{
+			"symbolic link to absolute path",
+			[]testEntry{{"a/b", tar.TypeSymlink, "/etc", ""}},
+			true, nil,
+		},
+		{
+			"hard link escapes",
+			[]testEntry{{"a/b", tar.TypeLink, "../pwned.txt", ""}},
+			true, nil,
+		},
+		{
+			"chained symbolic links escape",
+			[]testEntry{
+				{"a/b", tar.TypeSymlink, "..", ""},
+				{"a/b/c", tar.TypeSymlink, "..", ""},
+				{"a/b/c/pwned.txt", tar.TypeReg, "", "pwned"},
+			},
+			true, nil,
+		},
+		{
+			"symbolic link through extracted link",
+			[]testEntry{
+				{"p", tar.TypeSymlink, ".", ""},
+				{"q", tar.TypeSymlink, "p/..", ""},
+			},
+			true, nil,
+		},
+		{
+			"symbolic link through link extracted later",
+			[]testEntry{
+				{"q", tar.TypeSymlink, "p/..", ""},
+				{"p", tar.TypeSymlink, ".", ""},
+			},
+			true, nil,
+		},
+		{
+			"directory through symbolic link",
+			[]testEntry{
+				{"a/b", tar.TypeSymlink, "..", ""},
+				{"a/b/c/", tar.TypeDir, "", ""},
+			},
+			true, nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tmpDir, err := ioutil.TempDir("", "gopm-tz-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		// Destination is nested so escaped files are still in temporary directory.
+		srcPath := path.Join(tmpDir, "src.tar.gz")
+		destPath := path.Join(tmpDir, "root", "dest")
+		writeTestArchive(t, srcPath, tc.entries)
+
+		err = ExtractTo(srcPath, destPath)
+		if _, ok := err.(cae.ErrUnsafeEntry); ok != tc.isUnsafe {
+			t.Errorf("%s: expect unsafe entry error %v, got %v", tc.desc, tc.isUnsafe, err)
+		} else if !tc.isUnsafe && err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+		}
+		for _, name := range []string{"pwned.txt", "root/pwned.txt"} {
+			if _, err = os.Lstat(path.Join(tmpDir, name)); err == nil {
+				t.Errorf("%s: file written outside of destination: %s", tc.desc, name)
+			}
+		}
+		for _, name := range tc.files {
+			if _, err = os.Lstat(path.Join(destPath, name)); err != nil {
+				t.Errorf("%s: file not extracted: %v", tc.desc, err)
+			}
+		}
+	}
+}
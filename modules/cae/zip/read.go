@@ -53,7 +53,7 @@ func (z *ZipArchive) Open(name string, flag int, perm os.FileMode) error {
 
 	z.files = make([]*File, z.NumFiles)
 	for i, f := range rc.File {
-		z.files[i] = &File{}
+		z.files[i] = &File{zf: f}
 		z.files[i].FileHeader, err = zip.FileInfoHeader(f.FileInfo())
 		if err != nil {
 			return err
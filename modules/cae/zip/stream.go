@@ -18,38 +18,52 @@ import (
 	"archive/zip"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 )
 
 // A StreamArchive represents a streamable archive.
 type StreamArchive struct {
 	*zip.Writer
+	// IncludeDir option is ignored by streamer.
+	Options PackOptions
 }
 
 // NewStreamArachive returns a new streamable archive with given io.Writer.
 // It's caller's responsibility to close io.Writer and streamer after operation.
-func NewStreamArachive(w io.Writer) *StreamArchive {
-	return &StreamArchive{zip.NewWriter(w)}
+func NewStreamArachive(w io.Writer, opts ...PackOptions) *StreamArchive {
+	s := &StreamArchive{Writer: zip.NewWriter(w)}
+	if len(opts) > 0 {
+		s.Options = opts[0]
+	}
+	return s
+}
+
+// join returns entry name of given file in relative path.
+func (s *StreamArchive) join(relPath, name string) string {
+	if s.Options.Deterministic {
+		return path.Join(relPath, name)
+	}
+	return filepath.Join(relPath, name)
 }
 
 // StreamFile streams a file or directory entry into StreamArchive.
+// Note that entries are written in calling order even in deterministic mode,
+// caller should stream them in sorted order.
 func (s *StreamArchive) StreamFile(relPath string, fi os.FileInfo, data []byte) error {
 	if fi.IsDir() {
-		fh, err := zip.FileInfoHeader(fi)
+		fh, err := newHeader(relPath+"/", fi, &s.Options)
 		if err != nil {
 			return err
 		}
-		fh.Name = relPath + "/"
 		if _, err = s.Writer.CreateHeader(fh); err != nil {
 			return err
 		}
 	} else {
-		fh, err := zip.FileInfoHeader(fi)
+		fh, err := newHeader(s.join(relPath, fi.Name()), fi, &s.Options)
 		if err != nil {
 			return err
 		}
-		fh.Name = filepath.Join(relPath, fi.Name())
-		fh.Method = zip.Deflate
 		fw, err := s.Writer.CreateHeader(fh)
 		if err != nil {
 			return err
@@ -62,11 +76,10 @@ func (s *StreamArchive) StreamFile(relPath string, fi os.FileInfo, data []byte)
 
 // StreamReader streams data from io.Reader to StreamArchive.
 func (s *StreamArchive) StreamReader(relPath string, fi os.FileInfo, r io.Reader) (err error) {
-	fh, err := zip.FileInfoHeader(fi)
+	fh, err := newHeader(s.join(relPath, fi.Name()), fi, &s.Options)
 	if err != nil {
 		return err
 	}
-	fh.Name = filepath.Join(relPath, fi.Name())
 
 	fw, err := s.Writer.CreateHeader(fh)
 	if err != nil {
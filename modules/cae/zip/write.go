@@ -16,12 +16,16 @@ package zip
 
 import (
 	"archive/zip"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gpmgo/gopm/modules/cae"
 )
@@ -30,8 +34,13 @@ import (
 var Verbose = true
 
 // extractFile extracts zip.File to file system.
-func extractFile(f *zip.File, destPath string) error {
-	filePath := path.Join(destPath, f.Name)
+func extractFile(f *zip.File, destPath string, c *cae.Counter) error {
+	filePath, err := cae.SafeJoin(destPath, f.Name)
+	if err != nil {
+		return err
+	} else if err = cae.CheckParents(destPath, f.Name); err != nil {
+		return err
+	}
 	os.MkdirAll(path.Dir(filePath), os.ModePerm)
 
 	rc, err := f.Open()
@@ -40,20 +49,31 @@ func extractFile(f *zip.File, destPath string) error {
 	}
 	defer rc.Close()
 
+	// Symbolic link stores its target as content, it is extracted as regular file
+	// and skipped if it points to outside of destination.
+	if f.FileInfo().Mode()&os.ModeSymlink != 0 {
+		buf := new(bytes.Buffer)
+		if _, err = c.Copy(buf, rc); err != nil {
+			return err
+		} else if err = cae.CheckLink(f.Name, buf.String()); err != nil {
+			if Verbose {
+				fmt.Println("Skipping file..." + err.Error())
+			}
+			return nil
+		}
+		return ioutil.WriteFile(filePath, buf.Bytes(), 0644)
+	}
+
 	fw, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer fw.Close()
 
-	if _, err = io.Copy(fw, rc); err != nil {
+	if _, err = c.Copy(fw, rc); err != nil {
 		return err
 	}
 
-	// Skip symbolic links.
-	if f.FileInfo().Mode()&os.ModeSymlink != 0 {
-		return nil
-	}
 	// Set back file information.
 	if err = os.Chtimes(filePath, f.ModTime(), f.ModTime()); err != nil {
 		return err
@@ -80,42 +100,36 @@ func (z *ZipArchive) ExtractToFunc(destPath string, fn cae.HookFunc, entries ...
 		fmt.Println("Unzipping " + z.FileName + "...")
 	}
 	os.MkdirAll(destPath, os.ModePerm)
+	c := new(cae.Counter)
 	for _, f := range z.File {
 		f.Name = strings.Replace(f.Name, "\\", "/", -1)
+		if isHasEntry && !cae.IsEntry(f.Name, entries) {
+			continue
+		}
+		if err = c.AddFile(); err != nil {
+			return err
+		}
 
 		// Directory.
 		if strings.HasSuffix(f.Name, "/") {
-			if isHasEntry {
-				if cae.IsEntry(f.Name, entries) {
-					if err = fn(f.Name, f.FileInfo()); err != nil {
-						continue
-					}
-					os.MkdirAll(path.Join(destPath, f.Name), os.ModePerm)
-				}
-				continue
+			dirPath, err := cae.SafeJoin(destPath, f.Name)
+			if err != nil {
+				return err
+			} else if err = cae.CheckParents(destPath, f.Name); err != nil {
+				return err
 			}
 			if err = fn(f.Name, f.FileInfo()); err != nil {
 				continue
 			}
-			os.MkdirAll(path.Join(destPath, f.Name), os.ModePerm)
+			os.MkdirAll(dirPath, os.ModePerm)
 			continue
 		}
 
 		// File.
-		if isHasEntry {
-			if cae.IsEntry(f.Name, entries) {
-				if err = fn(f.Name, f.FileInfo()); err != nil {
-					continue
-				}
-				err = extractFile(f, destPath)
-			}
-		} else {
-			if err = fn(f.Name, f.FileInfo()); err != nil {
-				continue
-			}
-			err = extractFile(f, destPath)
+		if err = fn(f.Name, f.FileInfo()); err != nil {
+			continue
 		}
-		if err != nil {
+		if err = extractFile(f, destPath, c); err != nil {
 			return err
 		}
 	}
@@ -147,16 +161,57 @@ func ExtractTo(srcPath, destPath string, entries ...string) (err error) {
 	return ExtractToFunc(srcPath, destPath, defaultExtractFunc, entries...)
 }
 
-// extractFile extracts file from ZipArchive to file system.
-func (z *ZipArchive) extractFile(f *File) error {
-	if !z.isHasWriter {
-		for _, zf := range z.ReadCloser.File {
-			if f.Name == zf.Name {
-				return extractFile(zf, path.Dir(f.tmpPath))
-			}
+// writeFile writes an entry to zip.Writer. Entry from original archive
+// is copied without decompression and recompression.
+func writeFile(zw *zip.Writer, f *File) error {
+	if f.zf != nil {
+		fh := f.zf.FileHeader
+		fh.Name = f.Name
+		fw, err := zw.CreateRaw(&fh)
+		if err != nil {
+			return err
+		}
+		r, err := f.zf.OpenRaw()
+		if err != nil {
+			return err
 		}
+		_, err = io.Copy(fw, r)
+		return err
+	}
+
+	if strings.HasSuffix(f.Name, "/") {
+		_, err := zw.CreateHeader(f.FileHeader)
+		return err
+	}
+
+	f.Method = zip.Deflate
+	fw, err := zw.CreateHeader(f.FileHeader)
+	if err != nil {
+		return err
 	}
-	return cae.Copy(f.tmpPath, f.absPath)
+	fr, err := os.Open(f.absPath)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	_, err = io.Copy(fw, fr)
+	return err
+}
+
+// flushTo writes all entries of ZipArchive to io.Writer.
+func (z *ZipArchive) flushTo(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	if len(z.Comment) > 0 {
+		if err := zw.SetComment(z.Comment); err != nil {
+			return err
+		}
+	}
+	for _, f := range z.files {
+		if err := writeFile(zw, f); err != nil {
+			return fmt.Errorf("fail to write entry(%s): %v", f.Name, err)
+		}
+	}
+	return zw.Close()
 }
 
 // Flush saves changes to original zip file if any.
@@ -165,52 +220,122 @@ func (z *ZipArchive) Flush() error {
 		return nil
 	}
 
-	// Extract to tmp path and pack back.
-	tmpPath := path.Join(os.TempDir(), "cae", path.Base(z.FileName))
-	os.RemoveAll(tmpPath)
-	defer os.RemoveAll(tmpPath)
+	if z.isHasWriter {
+		return z.flushTo(z.writer)
+	}
 
-	for _, f := range z.files {
-		if strings.HasSuffix(f.Name, "/") {
-			os.MkdirAll(path.Join(tmpPath, f.Name), os.ModePerm)
-			continue
-		}
+	// Write to a temporary file in the same directory and replace original one.
+	tmp, err := ioutil.TempFile(path.Dir(z.FileName), "."+path.Base(z.FileName))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
 
-		// Relative path inside zip temporary changed.
-		f.tmpPath = path.Join(tmpPath, f.Name)
-		if err := z.extractFile(f); err != nil {
-			return err
-		}
+	// Keep permission of original file.
+	if fi, err := os.Stat(z.FileName); err == nil {
+		tmp.Chmod(fi.Mode())
 	}
 
-	if z.isHasWriter {
-		return packToWriter(tmpPath, z.writer, defaultPackFunc, true)
+	if err = z.flushTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	} else if err = tmp.Close(); err != nil {
+		return err
 	}
 
-	if err := PackTo(tmpPath, z.FileName); err != nil {
+	// Original file must be closed before replacing on Windows.
+	if err = z.ReadCloser.Close(); err != nil {
+		return err
+	}
+	z.ReadCloser = nil
+	if err = os.Rename(tmp.Name(), z.FileName); err != nil {
 		return err
 	}
-	return z.Open(z.FileName, os.O_RDWR|os.O_TRUNC, z.Permission)
+	return z.Open(z.FileName, os.O_RDWR, z.Permission)
+}
+
+// PackOptions represents options of packing archive.
+type PackOptions struct {
+	// Includes source directory itself as top level entry.
+	IncludeDir bool
+	// Deterministic mode makes same sources always produce same archive:
+	// entries are sorted by name, timestamps are fixed to ModTime,
+	// modes are normalized and no extra fields are written.
+	Deterministic bool
+	// Timestamp of all entries in deterministic mode,
+	// zero value means the earliest time zip format supports.
+	ModTime time.Time
+}
+
+// DefaultModTime is the timestamp used by deterministic mode
+// when no time is specified.
+var DefaultModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// timeToMsDosTime converts time.Time to MS-DOS date and time.
+func timeToMsDosTime(t time.Time) (fDate uint16, fTime uint16) {
+	fDate = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	fTime = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return fDate, fTime
+}
+
+// deterministicHeader returns a zip.FileHeader whose content only depends on
+// name, normalized mode and given timestamp.
+func deterministicHeader(name string, fi os.FileInfo, modTime time.Time) *zip.FileHeader {
+	if modTime.IsZero() {
+		modTime = DefaultModTime
+	}
+	modTime = modTime.UTC()
+	if modTime.Before(DefaultModTime) {
+		modTime = DefaultModTime
+	}
+
+	fh := &zip.FileHeader{
+		Name:   strings.Replace(name, "\\", "/", -1),
+		Method: zip.Deflate,
+	}
+	if fi.IsDir() {
+		fh.Method = zip.Store
+	} else {
+		fh.UncompressedSize64 = uint64(fi.Size())
+	}
+	// Do not use SetModTime or Modified field, they produce extra field.
+	fh.ModifiedDate, fh.ModifiedTime = timeToMsDosTime(modTime)
+	fh.SetMode(cae.NormMode(fi.Mode()))
+	return fh
+}
+
+// newHeader returns zip.FileHeader of given file information under options.
+func newHeader(name string, fi os.FileInfo, opt *PackOptions) (*zip.FileHeader, error) {
+	if opt.Deterministic {
+		return deterministicHeader(name, fi, opt.ModTime), nil
+	}
+
+	fh, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return nil, err
+	}
+	fh.Name = name
+	if !fi.IsDir() {
+		fh.Method = zip.Deflate
+	}
+	return fh, nil
 }
 
 // packFile packs a file or directory to zip.Writer.
-func packFile(srcFile string, recPath string, zw *zip.Writer, fi os.FileInfo) error {
+func packFile(srcFile string, recPath string, zw *zip.Writer, fi os.FileInfo, opt *PackOptions) error {
 	if fi.IsDir() {
-		fh, err := zip.FileInfoHeader(fi)
+		fh, err := newHeader(recPath+"/", fi, opt)
 		if err != nil {
 			return err
 		}
-		fh.Name = recPath + "/"
 		if _, err = zw.CreateHeader(fh); err != nil {
 			return err
 		}
 	} else {
-		fh, err := zip.FileInfoHeader(fi)
+		fh, err := newHeader(recPath, fi, opt)
 		if err != nil {
 			return err
 		}
-		fh.Name = recPath
-		fh.Method = zip.Deflate
 
 		fw, err := zw.CreateHeader(fh)
 		if err != nil {
@@ -230,6 +355,7 @@ func packFile(srcFile string, recPath string, zw *zip.Writer, fi os.FileInfo) er
 			if err != nil {
 				return err
 			}
+			defer f.Close()
 			if _, err = io.Copy(fw, f); err != nil {
 				return err
 			}
@@ -238,9 +364,15 @@ func packFile(srcFile string, recPath string, zw *zip.Writer, fi os.FileInfo) er
 	return nil
 }
 
+type fileInfos []os.FileInfo
+
+func (s fileInfos) Len() int           { return len(s) }
+func (s fileInfos) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s fileInfos) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 // packDir packs a directory and its subdirectories and files
 // recursively to zip.Writer.
-func packDir(srcPath string, recPath string, zw *zip.Writer, fn cae.HookFunc) error {
+func packDir(srcPath string, recPath string, zw *zip.Writer, fn cae.HookFunc, opt *PackOptions) error {
 	dir, err := os.Open(srcPath)
 	if err != nil {
 		return err
@@ -251,23 +383,29 @@ func packDir(srcPath string, recPath string, zw *zip.Writer, fn cae.HookFunc) er
 	if err != nil {
 		return err
 	}
+	if opt.Deterministic {
+		sort.Sort(fileInfos(fis))
+	}
 	for _, fi := range fis {
 		if cae.IsFilter(fi.Name()) {
 			continue
 		}
 		curPath := srcPath + "/" + fi.Name()
 		tmpRecPath := filepath.Join(recPath, fi.Name())
+		if opt.Deterministic {
+			tmpRecPath = path.Join(recPath, fi.Name())
+		}
 		if err = fn(curPath, fi); err != nil {
 			continue
 		}
 
 		if fi.IsDir() {
-			if err = packFile(srcPath, tmpRecPath, zw, fi); err != nil {
+			if err = packFile(srcPath, tmpRecPath, zw, fi, opt); err != nil {
 				return err
 			}
-			err = packDir(curPath, tmpRecPath, zw, fn)
+			err = packDir(curPath, tmpRecPath, zw, fn, opt)
 		} else {
-			err = packFile(curPath, tmpRecPath, zw, fi)
+			err = packFile(curPath, tmpRecPath, zw, fi, opt)
 		}
 		if err != nil {
 			return err
@@ -277,7 +415,7 @@ func packDir(srcPath string, recPath string, zw *zip.Writer, fn cae.HookFunc) er
 }
 
 // packToWriter packs given path object to io.Writer.
-func packToWriter(srcPath string, w io.Writer, fn func(fullName string, fi os.FileInfo) error, includeDir bool) error {
+func packToWriter(srcPath string, w io.Writer, fn func(fullName string, fi os.FileInfo) error, opt *PackOptions) error {
 	zw := zip.NewWriter(w)
 	defer zw.Close()
 
@@ -294,37 +432,46 @@ func packToWriter(srcPath string, w io.Writer, fn func(fullName string, fi os.Fi
 
 	basePath := path.Base(srcPath)
 	if fi.IsDir() {
-		if includeDir {
-			if err = packFile(srcPath, basePath, zw, fi); err != nil {
+		if opt.IncludeDir {
+			if err = packFile(srcPath, basePath, zw, fi, opt); err != nil {
 				return err
 			}
 		} else {
 			basePath = ""
 		}
-		return packDir(srcPath, basePath, zw, fn)
+		if err = packDir(srcPath, basePath, zw, fn, opt); err != nil {
+			return err
+		}
+	} else if err = packFile(srcPath, basePath, zw, fi, opt); err != nil {
+		return err
 	}
-	return packFile(srcPath, basePath, zw, fi)
+	return zw.Close()
 }
 
 // packTo packs given source path object to target path.
-func packTo(srcPath, destPath string, fn cae.HookFunc, includeDir bool) error {
+func packTo(srcPath, destPath string, fn cae.HookFunc, opt *PackOptions) error {
 	fw, err := os.Create(destPath)
 	if err != nil {
 		return err
 	}
 	defer fw.Close()
 
-	return packToWriter(srcPath, fw, fn, includeDir)
+	if err = packToWriter(srcPath, fw, fn, opt); err != nil {
+		return err
+	}
+	return fw.Close()
 }
 
 // PackToFunc packs the complete archive to the specified destination.
 // It accepts a function as a middleware for custom operations.
 func PackToFunc(srcPath, destPath string, fn func(fullName string, fi os.FileInfo) error, includeDir ...bool) error {
-	isIncludeDir := false
-	if len(includeDir) > 0 && includeDir[0] {
-		isIncludeDir = true
-	}
-	return packTo(srcPath, destPath, fn, isIncludeDir)
+	return PackToFuncOptions(srcPath, destPath, fn, PackOptions{IncludeDir: len(includeDir) > 0 && includeDir[0]})
+}
+
+// PackToFuncOptions works like PackToFunc with given options,
+// e.g. to produce deterministic archive.
+func PackToFuncOptions(srcPath, destPath string, fn func(fullName string, fi os.FileInfo) error, opt PackOptions) error {
+	return packTo(srcPath, destPath, fn, &opt)
 }
 
 var defaultPackFunc = func(fullName string, fi os.FileInfo) error {
@@ -0,0 +1,130 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package zip
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/gpmgo/gopm/modules/cae"
+)
+
+type testEntry struct {
+	name string
+	mode os.FileMode
+	body string
+}
+
+// writeTestArchive creates a zip file with given entries.
+func writeTestArchive(t *testing.T, name string, entries []testEntry) {
+	fw, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+
+	zw := zip.NewWriter(fw)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		h.SetMode(e.mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ExtractTo(t *testing.T) {
+	Verbose = false
+	testCases := []struct {
+		desc     string
+		entries  []testEntry
+		isUnsafe bool
+		files    []string // Files expected to be extracted.
+		skipped  []string // Files expected to be skipped.
+	}{
+		{
+			"regular files and link inside destination",
+			[]testEntry{
+				{"pkg/main.go", 0644, "package main"},
+				{"pkg/link.go", os.ModeSymlink | 0777, "main.go"},
+			},
+			false, []string{"pkg/main.go", "pkg/link.go"}, nil,
+		},
+		{
+			"relative path escapes",
+			[]testEntry{{"../pwned.txt", 0644, "pwned"}},
+			true, nil, nil,
+		},
+		{
+			"unsafe links are skipped",
+			[]testEntry{
+				{"pkg/main.go", 0644, "package main"},
+				{"pkg/up", os.ModeSymlink | 0777, "../.."},
+				{"pkg/etc", os.ModeSymlink | 0777, "/etc"},
+			},
+			false, []string{"pkg/main.go"}, []string{"pkg/up", "pkg/etc"},
+		},
+		{
+			"link through extracted link is skipped",
+			[]testEntry{
+				{"p", os.ModeSymlink | 0777, "."},
+				{"q", os.ModeSymlink | 0777, "p/.."},
+			},
+			false, []string{"p"}, []string{"q"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tmpDir, err := ioutil.TempDir("", "gopm-zip-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		srcPath := path.Join(tmpDir, "src.zip")
+		destPath := path.Join(tmpDir, "root", "dest")
+		writeTestArchive(t, srcPath, tc.entries)
+
+		err = ExtractTo(srcPath, destPath)
+		if _, ok := err.(cae.ErrUnsafeEntry); ok != tc.isUnsafe {
+			t.Errorf("%s: expect unsafe entry error %v, got %v", tc.desc, tc.isUnsafe, err)
+		} else if !tc.isUnsafe && err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+		}
+		if _, err = os.Lstat(path.Join(tmpDir, "root/pwned.txt")); err == nil {
+			t.Errorf("%s: file written outside of destination", tc.desc)
+		}
+		for _, name := range tc.files {
+			if _, err = os.Lstat(path.Join(destPath, name)); err != nil {
+				t.Errorf("%s: file not extracted: %v", tc.desc, err)
+			}
+		}
+		for _, name := range tc.skipped {
+			if _, err = os.Lstat(path.Join(destPath, name)); err == nil {
+				t.Errorf("%s: unsafe entry extracted: %s", tc.desc, name)
+			}
+		}
+	}
+}
@@ -30,10 +30,10 @@ import (
 // A File represents a file or directory entry in archive.
 type File struct {
 	*zip.FileHeader
-	oldName    string // NOTE: unused, for future change name feature.
-	oldComment string // NOTE: unused, for future change comment feature.
-	absPath    string // Absolute path of local file system.
-	tmpPath    string
+	oldName    string    // NOTE: unused, for future change name feature.
+	oldComment string    // NOTE: unused, for future change comment feature.
+	absPath    string    // Absolute path of local file system.
+	zf         *zip.File // Entry in original archive, nil for new entries.
 }
 
 // A ZipArchive represents a file archive, compressed with Zip.
@@ -198,9 +198,9 @@ func (z *ZipArchive) AddFile(fileName, absPath string) error {
 	z.AddEmptyDir(path.Dir(fileName))
 
 	isExist := false
-	for _, f := range z.files {
+	for i, f := range z.files {
 		if fileName == f.Name {
-			f = file
+			z.files[i] = file
 			isExist = true
 			break
 		}
@@ -220,6 +220,7 @@ func (z *ZipArchive) DeleteIndex(idx int) error {
 	}
 
 	z.files = append(z.files[:idx], z.files[idx+1:]...)
+	z.updateStat()
 	return nil
 }
 
@@ -0,0 +1,392 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+// A Credential represents authentication information of a host,
+// token is sent as bearer token and takes precedence over username and password.
+type Credential struct {
+	Username string
+	Password string
+	Token    string
+	Insecure bool // Allow to send over plain HTTP.
+}
+
+// IsEmpty returns true if credential has nothing to send.
+func (c *Credential) IsEmpty() bool {
+	return len(c.Token) == 0 && len(c.Username) == 0 && len(c.Password) == 0
+}
+
+// setHeader sets authorization header of request by credential.
+func (c *Credential) setHeader(req *http.Request) {
+	if len(c.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// authSection returns section name of authentication of given host in gopm.ini.
+func authSection(host string) string {
+	return "auth." + host
+}
+
+// envName returns name of environment variable of given host,
+// e.g. GOPM_TOKEN_GIT_EXAMPLE_COM for token of git.example.com.
+func envName(prefix, host string) string {
+	return prefix + "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// credentialFromEnv returns credential of given host from environment variables
+// GOPM_TOKEN_<HOST> or GOPM_AUTH_<HOST> in form of username:password.
+func credentialFromEnv(host string) *Credential {
+	if token := os.Getenv(envName("GOPM_TOKEN", host)); len(token) > 0 {
+		return &Credential{Token: token}
+	}
+	if auth := os.Getenv(envName("GOPM_AUTH", host)); len(auth) > 0 {
+		infos := strings.SplitN(auth, ":", 2)
+		c := &Credential{Username: infos[0]}
+		if len(infos) > 1 {
+			c.Password = infos[1]
+		}
+		return c
+	}
+	return nil
+}
+
+// credentialFromConfig returns credential of given host from [auth.<host>] section
+// of gopm.ini, values can refer to environment variables by ${NAME}.
+func credentialFromConfig(host string) *Credential {
+	if setting.Cfg == nil {
+		return nil
+	}
+	section := authSection(host)
+	c := &Credential{
+		Username: setting.Cfg.MustValue(section, "username"),
+		Password: setting.Cfg.MustValue(section, "password"),
+		Token:    setting.Cfg.MustValue(section, "token"),
+		Insecure: setting.Cfg.MustBool(section, "insecure"),
+	}
+	if c.IsEmpty() {
+		return nil
+	}
+	return c
+}
+
+// CredentialHelper returns credential helper command of given host,
+// 'helper' key in [auth.<host>] section of gopm.ini takes precedence
+// over CREDENTIAL_HELPER in [settings] section.
+func CredentialHelper(host string) string {
+	if setting.Cfg == nil {
+		return ""
+	}
+	if helper := setting.Cfg.MustValue(authSection(host), "helper"); len(helper) > 0 {
+		return helper
+	}
+	return setting.Cfg.MustValue("settings", "CREDENTIAL_HELPER")
+}
+
+// execCredentialHelper runs credential helper with given action
+// and attributes in protocol of git credential helpers:
+// attributes are written to stdin as key=value lines
+// and returned ones are read from stdout in the same format.
+func execCredentialHelper(helper, action string, attrs [][2]string) (map[string]string, error) {
+	args := strings.Fields(helper)
+	cmd := exec.Command(args[0], append(args[1:], action)...)
+
+	stdin := new(bytes.Buffer)
+	for _, attr := range attrs {
+		fmt.Fprintf(stdin, "%s=%s\n", attr[0], attr[1])
+	}
+	stdin.WriteString("\n")
+	cmd.Stdin = stdin
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) == 0 {
+			break
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			values[line[:i]] = line[i+1:]
+		}
+	}
+	return values, nil
+}
+
+// credentialFromHelper returns credential of given host from credential helper.
+// Besides username and password, helper can return token for bearer token.
+func credentialFromHelper(host string) *Credential {
+	helper := CredentialHelper(host)
+	if len(helper) == 0 {
+		return nil
+	}
+
+	values, err := execCredentialHelper(helper, "get", [][2]string{
+		{"protocol", "https"},
+		{"host", host},
+	})
+	if err != nil {
+		log.Warn("Fail to get credential of %s from helper: %v", host, err)
+		return nil
+	}
+	c := &Credential{
+		Username: values["username"],
+		Password: values["password"],
+		Token:    values["token"],
+	}
+	if c.IsEmpty() {
+		return nil
+	}
+	c.Insecure = setting.Cfg.MustBool(authSection(host), "insecure")
+	return c
+}
+
+// StoreCredential asks credential helper of given host to store credential,
+// so secrets do not need to be saved in gopm.ini.
+func StoreCredential(host string, c *Credential) error {
+	return storeCredential("https", host, c)
+}
+
+// OAUTH_PROTOCOL is the protocol attribute of OAuth application credentials
+// in credential helper, it differs from the one used by GetCredential so
+// client ID and secret are never sent as credential of the host.
+const OAUTH_PROTOCOL = "gopm-oauth"
+
+// StoreOAuthCredential asks credential helper of given host to store
+// client ID and secret of OAuth application as username and password.
+func StoreOAuthCredential(host string, c *Credential) error {
+	return storeCredential(OAUTH_PROTOCOL, host, c)
+}
+
+func storeCredential(protocol, host string, c *Credential) error {
+	helper := CredentialHelper(host)
+	if len(helper) == 0 {
+		return fmt.Errorf("no credential helper is configured for %s", host)
+	}
+
+	attrs := [][2]string{{"protocol", protocol}, {"host", host}}
+	if len(c.Username) > 0 {
+		attrs = append(attrs, [2]string{"username", c.Username})
+	}
+	if len(c.Password) > 0 {
+		attrs = append(attrs, [2]string{"password", c.Password})
+	}
+	if len(c.Token) > 0 {
+		attrs = append(attrs, [2]string{"token", c.Token})
+	}
+	if _, err := execCredentialHelper(helper, "store", attrs); err != nil {
+		return fmt.Errorf("fail to store credential of %s: %v", host, err)
+	}
+	return nil
+}
+
+// netrcPath returns path of netrc file, $NETRC takes precedence.
+func netrcPath() string {
+	if name := os.Getenv("NETRC"); len(name) > 0 {
+		return name
+	}
+	if setting.IsWindows {
+		return path.Join(setting.HomeDir, "_netrc")
+	}
+	return path.Join(setting.HomeDir, ".netrc")
+}
+
+// parseNetrc parses content of netrc file and returns credentials by machine name,
+// credential of default entry is keyed by empty string.
+func parseNetrc(data string) map[string]*Credential {
+	creds := make(map[string]*Credential)
+	var cur *Credential
+	var inMacro bool
+	for _, line := range strings.Split(data, "\n") {
+		// Macro definition ends with a blank line.
+		if inMacro {
+			if len(strings.TrimSpace(line)) == 0 {
+				inMacro = false
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				cur = nil
+				if i+1 < len(fields) {
+					i++
+					cur = &Credential{}
+					if _, ok := creds[fields[i]]; !ok {
+						creds[fields[i]] = cur
+					}
+				}
+			case "default":
+				cur = &Credential{}
+				if _, ok := creds[""]; !ok {
+					creds[""] = cur
+				}
+			case "login", "password", "account":
+				if i+1 >= len(fields) {
+					continue
+				}
+				i++
+				if cur != nil && fields[i-1] == "login" {
+					cur.Username = fields[i]
+				} else if cur != nil && fields[i-1] == "password" {
+					cur.Password = fields[i]
+				}
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return creds
+}
+
+var (
+	netrcOnce  sync.Once
+	netrcCreds map[string]*Credential
+)
+
+// credentialFromNetrc returns credential of given host from netrc file.
+// Only machine entries are used, default entry is ignored as go command does,
+// because requests are sent to any host that serves go-import meta tags.
+func credentialFromNetrc(host string) *Credential {
+	netrcOnce.Do(func() {
+		data, err := ioutil.ReadFile(netrcPath())
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warn("Fail to read netrc: %v", err)
+			}
+			return
+		}
+		netrcCreds = parseNetrc(string(data))
+	})
+
+	c := netrcCreds[host]
+	if c == nil || c.IsEmpty() {
+		return nil
+	}
+	return c
+}
+
+var (
+	credLocker sync.Mutex
+	credCache  = make(map[string]*Credential)
+)
+
+// GetCredential returns credential of given host, environment variables
+// take precedence over gopm.ini, then credential helper and netrc file.
+// It returns nil if no credential is available.
+func GetCredential(host string) *Credential {
+	credLocker.Lock()
+	defer credLocker.Unlock()
+
+	if c, ok := credCache[host]; ok {
+		return c
+	}
+
+	c := credentialFromEnv(host)
+	if c == nil {
+		c = credentialFromConfig(host)
+	}
+	if c == nil {
+		c = credentialFromHelper(host)
+	}
+	if c == nil {
+		c = credentialFromNetrc(host)
+	}
+	credCache[host] = c
+	return c
+}
+
+// authorize returns copy of request with authorization header if credential
+// of its host is available and request has not been authorized, otherwise
+// the request itself is returned. Credentials are only sent over HTTPS
+// unless host is marked as insecure.
+func authorize(req *http.Request) *http.Request {
+	if len(req.Header.Get("Authorization")) > 0 {
+		return req
+	}
+	c := GetCredential(req.URL.Hostname())
+	if c == nil {
+		return req
+	}
+	if req.URL.Scheme != "https" && !c.Insecure {
+		log.Debug("Skip sending credential over %s: %s", req.URL.Scheme, req.URL.Host)
+		return req
+	}
+
+	// RoundTripper must not modify the request.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	c.setHeader(r)
+	return r
+}
+
+// GitAuthArgs returns git options to fetch from given host by SSH
+// if it is enabled by 'ssh' key in [auth.<host>] section of gopm.ini.
+// Key file is set by 'ssh_key' and user by 'ssh_user'(default is git).
+func GitAuthArgs(host string) []string {
+	if setting.Cfg == nil {
+		return nil
+	}
+	section := authSection(host)
+	if !setting.Cfg.MustBool(section, "ssh") {
+		return nil
+	}
+
+	user := setting.Cfg.MustValue(section, "ssh_user", "git")
+	args := []string{"-c", fmt.Sprintf("url.ssh://%s@%s/.insteadOf=https://%s/", user, host, host)}
+	if key := setting.Cfg.MustValue(section, "ssh_key"); len(key) > 0 {
+		args = append(args, "-c", fmt.Sprintf("core.sshCommand=ssh -i '%s' -o IdentitiesOnly=yes", key))
+	}
+	return args
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_credentialFromNetrc(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gopm-netrc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	netrc := path.Join(tmpDir, "netrc")
+	if err = ioutil.WriteFile(netrc, []byte(`machine git.example.com login alice password secret
+macdef init
+machine macro.example.com login bob password macro
+
+default login carol password default
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	oldNetrc := os.Getenv("NETRC")
+	os.Setenv("NETRC", netrc)
+	defer os.Setenv("NETRC", oldNetrc)
+
+	testCases := []struct {
+		host     string
+		username string // Empty means no credential.
+	}{
+		{"git.example.com", "alice"},
+		// Default entry must not be sent to arbitrary hosts.
+		{"vanity.example.org", ""},
+		// Lines in macro definition are not entries.
+		{"macro.example.com", ""},
+	}
+	for _, tc := range testCases {
+		c := credentialFromNetrc(tc.host)
+		if len(tc.username) == 0 {
+			if c != nil {
+				t.Errorf("%s: expect no credential, got %s", tc.host, c.Username)
+			}
+			continue
+		}
+		if c == nil || c.Username != tc.username {
+			t.Errorf("%s: expect credential of %s, got %v", tc.host, tc.username, c)
+		}
+	}
+}
@@ -15,38 +15,98 @@
 package doc
 
 import (
-	"flag"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gpmgo/gopm/modules/log"
 	"github.com/gpmgo/gopm/modules/setting"
 )
 
-var (
-	dialTimeout    = flag.Duration("dial_timeout", 10*time.Second, "Timeout for dialing an HTTP connection.")
-	requestTimeout = flag.Duration("request_timeout", 20*time.Second, "Time out for roundtripping an HTTP request.")
-)
-
 func timeoutDial(network, addr string) (net.Conn, error) {
-	return net.DialTimeout(network, addr, *dialTimeout)
+	return net.DialTimeout(network, addr, setting.HttpDialTimeout)
 }
 
 type transport struct {
-	t http.Transport
+	t        http.Transport
+	proxyURL *url.URL
+	noProxy  []string
 }
 
-func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	timer := time.AfterFunc(*requestTimeout, func() {
+// roundTrip executes single request and cancels it when timeout.
+func (t *transport) roundTrip(req *http.Request) (*http.Response, error) {
+	timer := time.AfterFunc(setting.HttpRequestTimeout, func() {
 		t.t.CancelRequest(req)
-		log.Warn("Canceled request for %s, please interrupt the program.", req.URL)
+		log.Warn("Canceled request for %s", req.URL)
 	})
 	defer timer.Stop()
-	resp, err := t.t.RoundTrip(req)
-	return resp, err
+	return t.t.RoundTrip(req)
+}
+
+type noRetryKey struct{}
+
+// withoutRetry returns copy of request that transport does not retry,
+// for callers which retry on their own.
+func withoutRetry(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), noRetryKey{}, true))
+}
+
+// canRetry returns true if request can be sent again
+// for given response or error.
+func canRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	} else if req.Context().Value(noRetryKey{}) != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// backoff returns jittered wait duration before given retry attempt,
+// which grows exponentially from HTTP_RETRY_WAIT to HTTP_RETRY_MAX_WAIT.
+func backoff(attempt int) time.Duration {
+	wait := setting.HttpRetryWait
+	for i := 0; i < attempt && wait < setting.HttpRetryMaxWait; i++ {
+		wait *= 2
+	}
+	if wait > setting.HttpRetryMaxWait {
+		wait = setting.HttpRetryMaxWait
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// Wait between half and one and a half of duration.
+	return wait/2 + time.Duration(rand.Int63n(int64(wait)))
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = authorize(req)
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(req)
+		if attempt >= setting.HttpRetries || !canRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := backoff(attempt)
+		if err != nil {
+			log.Warn("Request for %s failed, retry in %s: %v", req.URL, wait, err)
+		} else {
+			log.Warn("Request for %s returned %s, retry in %s", req.URL, resp.Status, wait)
+			resp.Body.Close()
+		}
+		time.Sleep(wait)
+	}
 }
 
 func (t *transport) SetProxy(proxy string) error {
@@ -62,20 +122,132 @@ func (t *transport) SetProxy(proxy string) error {
 		log.Error("Fail to set HTTP proxy:")
 		log.Fatal("\t%v", err)
 	}
-	t.t.Proxy = http.ProxyURL(proxyUrl)
+	t.proxyURL = proxyUrl
 	return nil
 }
 
+// matchNoProxy returns true if given host matches any entry of
+// NO_PROXY-style list: '*' matches all hosts, domain matches itself
+// and its subdomains, IP address and CIDR match addresses.
+func matchNoProxy(host string, list []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range list {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		switch {
+		case len(entry) == 0:
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil && ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+		case ip != nil:
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, "*")
+			entry = strings.TrimPrefix(entry, ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxy returns proxy URL of request, proxy in gopm.ini takes precedence
+// over HTTP_PROXY and HTTPS_PROXY environment variables.
+func (t *transport) proxy(req *http.Request) (*url.URL, error) {
+	if matchNoProxy(req.URL.Hostname(), t.noProxy) {
+		return nil, nil
+	}
+	if t.proxyURL != nil {
+		return t.proxyURL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// tlsConfig returns TLS configuration with custom CA bundle
+// and client certificate in gopm.ini, or nil if neither is set.
+func tlsConfig() (*tls.Config, error) {
+	if len(setting.HttpCAFile) == 0 && len(setting.HttpCertFile) == 0 {
+		return nil, nil
+	}
+
+	cfg := new(tls.Config)
+	if len(setting.HttpCAFile) > 0 {
+		data, err := ioutil.ReadFile(setting.HttpCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read CA file: %v", err)
+		}
+		// Custom CAs are trusted in addition to the system ones.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in CA file: %s", setting.HttpCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if len(setting.HttpCertFile) > 0 {
+		keyFile := setting.HttpKeyFile
+		if len(keyFile) == 0 {
+			keyFile = setting.HttpCertFile
+		}
+		cert, err := tls.LoadX509KeyPair(setting.HttpCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("fail to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
 var (
 	httpTransport = &transport{
 		t: http.Transport{
 			Dial:                  timeoutDial,
-			ResponseHeaderTimeout: *requestTimeout / 2,
+			ResponseHeaderTimeout: setting.HttpRequestTimeout / 2,
 		},
 	}
 	HttpClient = &http.Client{Transport: httpTransport}
 )
 
+func init() {
+	httpTransport.t.Proxy = httpTransport.proxy
+}
+
 func SetProxy(proxy string) error {
 	return httpTransport.SetProxy(proxy)
 }
+
+// SetMaxConnsPerHost limits number of connections per host,
+// zero means no limit.
+func SetMaxConnsPerHost(num int) {
+	httpTransport.t.MaxConnsPerHost = num
+}
+
+// SetupHTTP applies HTTP settings of gopm.ini to HttpClient,
+// including timeouts, proxy, proxy bypass list, CA bundle
+// and client certificate.
+func SetupHTTP() error {
+	if err := SetProxy(setting.HttpProxy); err != nil {
+		return err
+	}
+	httpTransport.noProxy = strings.Split(setting.HttpNoProxy, ",")
+	httpTransport.t.ResponseHeaderTimeout = setting.HttpRequestTimeout / 2
+	SetMaxConnsPerHost(setting.HttpMaxConnsPerHost)
+
+	cfg, err := tlsConfig()
+	if err != nil {
+		return err
+	}
+	httpTransport.t.TLSClientConfig = cfg
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+// A httpCacheEntry represents a cached HTTP response.
+type httpCacheEntry struct {
+	URL          string `json:"url"`
+	Status       int    `json:"status"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Expires      int64  `json:"expires"` // Response is fresh until this Unix time.
+	Body         []byte `json:"body"`
+}
+
+var (
+	cacheStatsLocker sync.Mutex
+	// Number of responses served from cache without request,
+	// revalidated by 304 and fetched in full.
+	cacheHits, cacheRevalidated, cacheMisses int
+)
+
+func countCache(counter *int) {
+	cacheStatsLocker.Lock()
+	*counter++
+	cacheStatsLocker.Unlock()
+}
+
+// LogHttpCacheStats prints hit and miss counts of HTTP cache in verbose mode.
+func LogHttpCacheStats() {
+	if cacheHits+cacheRevalidated+cacheMisses == 0 {
+		return
+	}
+	log.Info("HTTP cache: %d hit(s), %d revalidated, %d miss(es)",
+		cacheHits, cacheRevalidated, cacheMisses)
+}
+
+func httpCachePath(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return path.Join(setting.HttpCacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+func readHttpCache(url string) *httpCacheEntry {
+	data, err := ioutil.ReadFile(httpCachePath(url))
+	if err != nil {
+		return nil
+	}
+	entry := new(httpCacheEntry)
+	if err = json.Unmarshal(data, entry); err != nil || entry.URL != url {
+		return nil
+	}
+	return entry
+}
+
+func writeHttpCache(entry *httpCacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		os.MkdirAll(setting.HttpCacheDir, os.ModePerm)
+		err = base.WriteFileAtomic(httpCachePath(entry.URL), data)
+	}
+	if err != nil {
+		log.Warn("Fail to save HTTP cache: %v", err)
+	}
+}
+
+// parseCacheControl returns max-age of Cache-Control header or -1 if absent,
+// and whether response must not be stored or must be revalidated.
+func parseCacheControl(header string) (maxAge int, noStore, noCache bool) {
+	maxAge = -1
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			noStore = true
+		case directive == "no-cache":
+			noCache = true
+		case strings.HasPrefix(directive, "max-age="):
+			if age, err := strconv.Atoi(strings.Trim(directive[len("max-age="):], `"`)); err == nil {
+				maxAge = age
+			}
+		}
+	}
+	return maxAge, noStore, noCache
+}
+
+// freshUntil returns Unix time until which response is fresh
+// by Cache-Control or Expires header.
+func freshUntil(header http.Header) int64 {
+	maxAge, _, noCache := parseCacheControl(header.Get("Cache-Control"))
+	switch {
+	case noCache:
+		return 0
+	case maxAge >= 0:
+		return time.Now().Unix() + int64(maxAge)
+	}
+	if t, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return t.Unix()
+	}
+	return 0
+}
+
+// cachedGet gets resource of given URL through HTTP cache,
+// fresh response is used without request and stale one
+// is revalidated by ETag or Last-Modified. Revalidation is forced
+// if refresh is true. It returns status code and body of response.
+func cachedGet(client *http.Client, url string, refresh bool) (int, []byte, error) {
+	var entry *httpCacheEntry
+	if len(setting.HttpCacheDir) > 0 {
+		entry = readHttpCache(url)
+	}
+	if entry != nil && !refresh && time.Now().Unix() < entry.Expires {
+		log.Debug("HTTP cache hit: %s", url)
+		countCache(&cacheHits)
+		return entry.Status, entry.Body, nil
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if entry != nil {
+		if len(entry.ETag) > 0 {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if len(entry.LastModified) > 0 {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		log.Debug("HTTP cache revalidated: %s", url)
+		countCache(&cacheRevalidated)
+		entry.Expires = freshUntil(resp.Header)
+		if etag := resp.Header.Get("ETag"); len(etag) > 0 {
+			entry.ETag = etag
+		}
+		writeHttpCache(entry)
+		return entry.Status, entry.Body, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	log.Debug("HTTP cache miss: %s", url)
+	countCache(&cacheMisses)
+
+	_, noStore, _ := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if len(setting.HttpCacheDir) > 0 && resp.StatusCode == http.StatusOK && !noStore {
+		entry = &httpCacheEntry{
+			URL:          url,
+			Status:       resp.StatusCode,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Expires:      freshUntil(resp.Header),
+			Body:         body,
+		}
+		// Response cannot be reused without validator or freshness.
+		if len(entry.ETag) > 0 || len(entry.LastModified) > 0 || entry.Expires > time.Now().Unix() {
+			writeHttpCache(entry)
+		}
+	}
+	return resp.StatusCode, body, nil
+}
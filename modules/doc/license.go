@@ -0,0 +1,162 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Special license names for package without license file
+// and license file cannot be classified.
+const (
+	LICENSE_NONE    = "None"
+	LICENSE_UNKNOWN = "Unknown"
+)
+
+// A License represents a license file of package.
+type License struct {
+	Name string `json:"name"` // SPDX identifier, or LICENSE_UNKNOWN.
+	File string `json:"file"`
+}
+
+// licenseRule matches normalized license text which contains all phrases
+// of All and any phrase of Any if it is not empty.
+type licenseRule struct {
+	Name string
+	Any  []string
+	All  []string
+}
+
+// gnuPhrases returns phrases of title and notice of GNU license.
+func gnuPhrases(name, version string) []string {
+	return []string{
+		"gnu " + name + " version " + version,
+		"gnu " + name + " as published by the free software foundation either version " + version,
+	}
+}
+
+const bsdPhrase = "redistribution and use in source and binary forms with or without modification are permitted"
+
+// licenseRules is the list of license rules in order of precedence,
+// variants go before the licenses they mention or derive from.
+var licenseRules = []licenseRule{
+	// MPL lists GNU licenses as secondary licenses.
+	{"MPL-2.0", []string{"mozilla public license version 2.0", "mozilla public license v. 2.0"}, nil},
+	{"AGPL-3.0", gnuPhrases("affero general public license", "3"), nil},
+	{"LGPL-3.0", gnuPhrases("lesser general public license", "3"), nil},
+	{"LGPL-2.1", gnuPhrases("lesser general public license", "2.1"), nil},
+	{"LGPL-2.0", gnuPhrases("library general public license", "2"), nil},
+	{"GPL-3.0", gnuPhrases("general public license", "3"), nil},
+	{"GPL-2.0", gnuPhrases("general public license", "2"), nil},
+	{"Apache-2.0", []string{"apache license version 2.0"}, nil},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}, nil},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}, nil},
+	{"ISC", []string{"for any purpose with or without fee is hereby granted provided that the above copyright notice and this permission notice appear in all copies"}, nil},
+	{"MIT", []string{"permission is hereby granted free of charge to any person obtaining a copy"}, nil},
+	{"BSD-4-Clause", nil, []string{bsdPhrase, "all advertising materials mentioning features or use of this software"}},
+	{"BSD-3-Clause", nil, []string{bsdPhrase, "endorse or promote products derived from this software"}},
+	{"BSD-2-Clause", nil, []string{bsdPhrase}},
+}
+
+func (r *licenseRule) match(text string) bool {
+	for _, phrase := range r.All {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+	if len(r.Any) == 0 {
+		return true
+	}
+	for _, phrase := range r.Any {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLicense lowercases license text and replaces punctuations
+// and line breaks with single space, dots are kept for version numbers.
+func normalizeLicense(text string) string {
+	return strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)), " ")
+}
+
+// ClassifyLicense returns SPDX identifier of license by its text,
+// or LICENSE_UNKNOWN if it does not match any known license.
+func ClassifyLicense(text string) string {
+	text = normalizeLicense(text)
+	for i := range licenseRules {
+		if licenseRules[i].match(text) {
+			return licenseRules[i].Name
+		}
+	}
+	return LICENSE_UNKNOWN
+}
+
+// IsKnownLicense returns true if given name is a license name
+// returned by ClassifyLicense or LICENSE_NONE, case insensitive.
+func IsKnownLicense(name string) bool {
+	if strings.EqualFold(name, LICENSE_NONE) || strings.EqualFold(name, LICENSE_UNKNOWN) {
+		return true
+	}
+	for _, r := range licenseRules {
+		if strings.EqualFold(name, r.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLicenseFile returns true if given file name looks like a license file,
+// e.g. LICENSE, LICENSE.txt, LICENSE-MIT, COPYING and COPYING.LESSER.
+func isLicenseFile(name string) bool {
+	name = strings.ToUpper(name)
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectLicenses returns licenses of package in given directory
+// by its license files, it returns nil if package has no license file.
+func DetectLicenses(dirPath string) ([]*License, error) {
+	fis, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []*License
+	for _, fi := range fis {
+		if fi.IsDir() || !isLicenseFile(fi.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(path.Join(dirPath, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		licenses = append(licenses, &License{ClassifyLicense(string(data)), fi.Name()})
+	}
+	return licenses, nil
+}
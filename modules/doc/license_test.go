@@ -0,0 +1,156 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const (
+	testBSDClauses = `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice.
+`
+	testBSD3Clause = `3. Neither the name of the copyright holder nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+`
+	testBSD4Clause = `3. All advertising materials mentioning features or use of this software
+must display the following acknowledgement.
+`
+)
+
+func Test_ClassifyLicense(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		text   string
+		expect string
+	}{
+		{"MIT", `Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software")`, "MIT"},
+		{"Apache", `                                 Apache License
+                           Version 2.0, January 2004`, "Apache-2.0"},
+		{"GPL v2", `		    GNU GENERAL PUBLIC LICENSE
+		       Version 2, June 1991`, "GPL-2.0"},
+		{"GPL v3 notice", `This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.`, "GPL-3.0"},
+		{"LGPL v3 mentions GPL", `                   GNU LESSER GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+  This version of the GNU Lesser General Public License incorporates
+the terms and conditions of version 3 of the GNU General Public
+License, supplemented by the additional permissions listed below.`, "LGPL-3.0"},
+		{"LGPL v2.1", `GNU LESSER GENERAL PUBLIC LICENSE
+Version 2.1, February 1999`, "LGPL-2.1"},
+		{"LGPL v2", `GNU LIBRARY GENERAL PUBLIC LICENSE
+Version 2, June 1991`, "LGPL-2.0"},
+		{"AGPL v3", `GNU AFFERO GENERAL PUBLIC LICENSE
+Version 3, 19 November 2007`, "AGPL-3.0"},
+		{"MPL mentions GPL", `Mozilla Public License Version 2.0
+1.12. "Secondary License" means either the GNU General Public License,
+Version 2.0, the GNU Lesser General Public License, Version 2.1`, "MPL-2.0"},
+		{"ISC", `Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.`, "ISC"},
+		{"Unlicense", `This is free and unencumbered software released into the public domain.`, "Unlicense"},
+		{"CC0", `Creative Commons Legal Code
+
+CC0 1.0 Universal`, "CC0-1.0"},
+		{"BSD 2-Clause", testBSDClauses, "BSD-2-Clause"},
+		{"BSD 3-Clause", testBSDClauses + testBSD3Clause, "BSD-3-Clause"},
+		{"BSD 4-Clause", testBSDClauses + testBSD4Clause + testBSD3Clause, "BSD-4-Clause"},
+		{"unknown", "All rights reserved.", LICENSE_UNKNOWN},
+		{"empty", "", LICENSE_UNKNOWN},
+	}
+	for _, tc := range testCases {
+		if name := ClassifyLicense(tc.text); name != tc.expect {
+			t.Errorf("%s: expect '%s', got '%s'", tc.desc, tc.expect, name)
+		}
+	}
+}
+
+func Test_IsKnownLicense(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expect bool
+	}{
+		{"MIT", true},
+		{"gpl-3.0", true},
+		{"bsd-3-clause", true},
+		{"none", true},
+		{"Unknown", true},
+		{"GPL", false},
+		{"WTFPL", false},
+	}
+	for _, tc := range testCases {
+		if IsKnownLicense(tc.name) != tc.expect {
+			t.Errorf("%s: expect %v", tc.name, tc.expect)
+		}
+	}
+}
+
+func Test_DetectLicenses(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gopm-license-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"LICENSE-MIT":    "Permission is hereby granted, free of charge, to any person obtaining a copy",
+		"COPYING.LESSER": "GNU Lesser General Public License, Version 2.1",
+		"licence.txt":    "All rights reserved.",
+		"README.md":      "Apache License, Version 2.0",
+		"main.go":        "package main",
+	}
+	for name, data := range files {
+		if err = ioutil.WriteFile(path.Join(tmpDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Directory is not a license file.
+	os.Mkdir(path.Join(tmpDir, "LICENSES"), os.ModePerm)
+
+	licenses, err := DetectLicenses(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"COPYING.LESSER": "LGPL-2.1",
+		"LICENSE-MIT":    "MIT",
+		"licence.txt":    LICENSE_UNKNOWN,
+	}
+	if len(licenses) != len(expect) {
+		t.Fatalf("expect %d licenses, got %d", len(expect), len(licenses))
+	}
+	for _, l := range licenses {
+		if l.Name != expect[l.File] {
+			t.Errorf("%s: expect '%s', got '%s'", l.File, expect[l.File], l.Name)
+		}
+	}
+
+	// Package without license file.
+	os.RemoveAll(tmpDir)
+	os.MkdirAll(tmpDir, os.ModePerm)
+	if licenses, err = DetectLicenses(tmpDir); err != nil || licenses != nil {
+		t.Errorf("expect no license, got %v (%v)", licenses, err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/log"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+// A metaEntry represents go-import meta tag of a project root
+// and when it was fetched.
+type metaEntry struct {
+	Match   map[string]string `json:"match"`
+	Updated int64             `json:"updated"`
+}
+
+// metaCache contains go-import meta tags keyed by project root,
+// nil means it has not been loaded yet.
+var metaCache map[string]*metaEntry
+
+func readMetaCache() (map[string]*metaEntry, error) {
+	cache := make(map[string]*metaEntry)
+	data, err := ioutil.ReadFile(setting.MetaCacheFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &cache); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func loadMetaCache() {
+	if metaCache != nil {
+		return
+	}
+
+	var err error
+	if len(setting.MetaCacheFile) > 0 {
+		if metaCache, err = readMetaCache(); err != nil {
+			log.Warn("Fail to load meta cache: %v", err)
+		}
+	}
+	if metaCache == nil {
+		metaCache = make(map[string]*metaEntry)
+	}
+}
+
+// getCachedMeta returns cached go-import meta tag of project
+// that given import path belongs to, and whether it is expired.
+func getCachedMeta(importPath string) (map[string]string, bool) {
+	loadMetaCache()
+	for root := importPath; ; {
+		if e, ok := metaCache[root]; ok {
+			match := make(map[string]string, len(e.Match))
+			for k, v := range e.Match {
+				match[k] = v
+			}
+			match["importPath"] = importPath
+			match["dir"] = importPath[len(root):]
+			return match, time.Since(time.Unix(e.Updated, 0)) > setting.MetaCacheTTL
+		}
+
+		i := strings.LastIndex(root, "/")
+		if i < 0 {
+			return nil, false
+		}
+		root = root[:i]
+	}
+}
+
+// saveCachedMeta merges go-import meta tag of project root
+// into latest cache on disk under lock and saves it.
+func saveCachedMeta(match map[string]string) error {
+	loadMetaCache()
+	e := &metaEntry{match, time.Now().Unix()}
+	metaCache[match["projectRoot"]] = e
+	if len(setting.MetaCacheFile) == 0 {
+		return nil
+	}
+
+	lock, err := base.LockFile(setting.MetaCacheFile + ".lock")
+	if err != nil {
+		return fmt.Errorf("fail to lock meta cache: %v", err)
+	}
+	defer lock.Unlock()
+
+	cache, err := readMetaCache()
+	if err != nil {
+		// Broken cache is simply overwritten.
+		cache = make(map[string]*metaEntry)
+	}
+	cache[match["projectRoot"]] = e
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return base.WriteFileAtomic(setting.MetaCacheFile, data)
+}
@@ -0,0 +1,227 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	godoc "go/doc"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/setting"
+)
+
+// GetSynopsis returns synopsis of package documentation in given directory,
+// or empty string if package has no documentation.
+func GetSynopsis(dirPath string) string {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dirPath, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return ""
+	}
+
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			if f.Doc != nil {
+				return godoc.Synopsis(f.Doc.Text())
+			}
+		}
+	}
+	return ""
+}
+
+// hasGoFiles returns true if given directory contains any non-test Go file.
+func hasGoFiles(dirPath string) bool {
+	fis, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return false
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if !fi.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalNode returns true if given project root is recorded in local nodes.
+func isLocalNode(rootPath string) bool {
+	return setting.LocalNodes != nil &&
+		setting.LocalNodes.MustValue(rootPath, "value", "\x00") != "\x00"
+}
+
+// splitVersion splits directory name of project root in local repository
+// into name and revision value, e.g. 'cli.v1.0' to 'cli' and 'v1.0'.
+// Name is the shortest prefix which is also a cached project root,
+// so names contain dots are not split by mistake.
+func splitVersion(rootDir string) (string, string) {
+	// Directory name itself is a recorded project root.
+	if isLocalNode(rootDir) {
+		return rootDir, ""
+	}
+
+	parent, dirName := path.Split(rootDir)
+	for i := 1; i < len(dirName); i++ {
+		if dirName[i] != '.' {
+			continue
+		}
+		name := path.Join(parent, dirName[:i])
+		if isLocalNode(name) || base.IsDir(path.Join(setting.InstallRepoPath, name)) {
+			return name, dirName[i+1:]
+		}
+	}
+	return rootDir, ""
+}
+
+// ListCachedPackages returns packages in local repository with revision value
+// and synopsis of each one. Root paths are determined without network request.
+func ListCachedPackages() ([]*Node, error) {
+	nodes := make([]*Node, 0, 10)
+	err := filepath.Walk(setting.InstallRepoPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return nil
+		}
+		name := fi.Name()
+		if strings.Contains(name, ".staging-") || name == "testdata" ||
+			(strings.HasPrefix(name, ".") && name != ".") || strings.HasPrefix(name, "_") {
+			return filepath.SkipDir
+		}
+		if !hasGoFiles(p) {
+			return nil
+		}
+
+		rel := strings.TrimPrefix(filepath.ToSlash(p), setting.InstallRepoPath+"/")
+		rootDir, ok := localRootPath(rel)
+		if !ok {
+			rootDir = rel
+		}
+		rootPath, val := splitVersion(rootDir)
+
+		n := &Node{
+			Pkg: Pkg{
+				ImportPath: rootPath + strings.TrimPrefix(rel, rootDir),
+				RootPath:   rootPath,
+				Value:      val,
+			},
+			InstallPath: p,
+			Synopsis:    GetSynopsis(p),
+		}
+		// Type of fixed revision is unknown by directory name.
+		if len(val) == 0 {
+			n.Type = BRANCH
+		}
+		nodes = append(nodes, n)
+		return nil
+	})
+	return nodes, err
+}
+
+// ListCachedVersions returns revisions of given project root in local repository,
+// empty value stands for latest revision of default branch, whose commit
+// is recorded in local nodes.
+func ListCachedVersions(rootPath string) ([]*Node, error) {
+	parent, dirName := path.Split(path.Join(setting.InstallRepoPath, rootPath))
+	fis, err := ioutil.ReadDir(parent)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	nodes := make([]*Node, 0, 3)
+	for _, fi := range fis {
+		name := fi.Name()
+		if !fi.IsDir() || strings.Contains(name, ".staging-") ||
+			(name != dirName && !strings.HasPrefix(name, dirName+".")) {
+			continue
+		}
+		// Other project may have name with same prefix.
+		rootDir, val := splitVersion(path.Join(path.Dir(rootPath), name))
+		if rootDir != rootPath {
+			continue
+		}
+
+		n := &Node{
+			Pkg: Pkg{
+				ImportPath: rootPath,
+				RootPath:   rootPath,
+				Value:      val,
+			},
+			InstallPath: path.Join(parent, name),
+		}
+		if len(val) == 0 {
+			n.Type = BRANCH
+			if setting.LocalNodes != nil {
+				n.Revision = setting.LocalNodes.MustValue(rootPath, "value")
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// A SearchResult represents a package returned by registry search.
+type SearchResult struct {
+	ImportPath string `json:"import_path"`
+	Synopsis   string `json:"synopsis"`
+}
+
+// SearchRegistry searches packages by given keyword on gopm registry.
+func SearchRegistry(keyword string) ([]*Node, error) {
+	status, body, err := cachedGet(HttpClient, fmt.Sprintf("%s%s?q=%s",
+		setting.RegistryURL, setting.URL_API_SEARCH, url.QueryEscape(keyword)), false)
+	if err != nil {
+		return nil, fmt.Errorf("fail to make request: %v", err)
+	}
+	if status != 200 {
+		var apiErr ApiError
+		if err = json.Unmarshal(body, &apiErr); err != nil || len(apiErr.Error) == 0 {
+			return nil, fmt.Errorf("registry does not support search: status %d", status)
+		}
+		return nil, errors.New(apiErr.Error)
+	}
+
+	var results []SearchResult
+	if err = json.Unmarshal(body, &results); err != nil {
+		return nil, fmt.Errorf("fail to decode response JSON: %v", err)
+	}
+	nodes := make([]*Node, 0, len(results))
+	for _, r := range results {
+		if !base.IsValidRemotePath(r.ImportPath) {
+			continue
+		}
+		n := &Node{
+			Pkg: Pkg{
+				ImportPath: r.ImportPath,
+				Type:       BRANCH,
+			},
+			Synopsis: r.Synopsis,
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
@@ -15,6 +15,7 @@
 package doc
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -28,6 +29,8 @@ import (
 	"time"
 
 	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/cae"
+	"github.com/gpmgo/gopm/modules/cae/tz"
 	"github.com/gpmgo/gopm/modules/cae/zip"
 	"github.com/gpmgo/gopm/modules/cli"
 	"github.com/gpmgo/gopm/modules/log"
@@ -120,6 +123,12 @@ type Node struct {
 	IsGetDeps     bool // False for downloading package itself only.
 	IsGetDepsOnly bool // True for skiping download package itself.
 	Revision      string
+
+	// URL templates of upstream source from go-source meta tag.
+	SourceHome string
+	SourceDir  string
+	SourceFile string
+	sourceRoot string
 }
 
 // NewNode initializes and returns a new Node representation.
@@ -164,6 +173,48 @@ func (n *Node) VerString() string {
 	return fmt.Sprintf("%s@%s:%s", n.ImportPath, n.Type, n.ValString())
 }
 
+// setSource sets upstream source of package by go-import meta tag match.
+func (n *Node) setSource(match map[string]string) {
+	n.SourceHome = match["sourceHome"]
+	n.SourceDir = match["sourceDir"]
+	n.SourceFile = match["sourceFile"]
+	n.sourceRoot = match["projectRoot"]
+}
+
+// expandSource expands go-source URL template with given file and line.
+func (n *Node) expandSource(template, file string, line int) string {
+	dir := strings.TrimPrefix(strings.TrimPrefix(n.ImportPath, n.sourceRoot), "/")
+	slashDir := dir
+	if len(dir) > 0 {
+		slashDir = "/" + dir
+	}
+	return strings.NewReplacer(
+		"{dir}", dir,
+		"{/dir}", slashDir,
+		"{file}", file,
+		"{line}", fmt.Sprint(line),
+	).Replace(template)
+}
+
+// SourceURL returns URL of package directory in upstream source,
+// home page is returned if directory template is not available.
+// It returns empty string if package has no go-source meta tag.
+func (n *Node) SourceURL() string {
+	if len(n.SourceDir) == 0 || n.SourceDir == "_" {
+		return n.SourceHome
+	}
+	return n.expandSource(n.SourceDir, "", 0)
+}
+
+// SourceFileURL returns URL of given file at given line in upstream source,
+// or empty string if file template is not available.
+func (n *Node) SourceFileURL(file string, line int) string {
+	if len(n.SourceFile) == 0 || n.SourceFile == "_" {
+		return ""
+	}
+	return n.expandSource(n.SourceFile, file, line)
+}
+
 func (n *Node) HasVcs() bool {
 	return len(GetVcsName(n.InstallGopath)) > 0
 }
@@ -199,8 +250,9 @@ func (n *Node) UpdateByVcs(vcs string) error {
 		}
 		branch = strings.TrimSpace(branch)
 
+		host := strings.Split(n.RootPath, "/")[0]
 		_, stderr, err = base.ExecCmdDir(n.InstallGopath,
-			"git", "pull", "origin", branch)
+			"git", append(GitAuthArgs(host), "pull", "origin", branch)...)
 		if err != nil {
 			log.Error("Error occurs when 'git pull origin %s'", branch)
 			log.Error("\t%s", stderr)
@@ -243,8 +295,35 @@ func attrValue(attrs []xml.Attr, name string) string {
 	return ""
 }
 
+// vcsPreference is the order of VCS to choose when a page lists
+// multiple go-import meta tags with same prefix, lower is better.
+// VCS not in the list(e.g. mod) are not supported.
+var vcsPreference = map[string]int{
+	"git": 1,
+	"hg":  2,
+	"svn": 3,
+	"bzr": 4,
+}
+
+// metaImport represents content of a go-import meta tag.
+type metaImport struct {
+	prefix, vcs, repo string
+}
+
+// metaSource represents content of a go-source meta tag.
+type metaSource struct {
+	prefix, home, dir, file string
+}
+
+// hasPathPrefix returns true if prefix is importPath or its parent.
+func hasPathPrefix(importPath, prefix string) bool {
+	return strings.HasPrefix(importPath, prefix) &&
+		(len(importPath) == len(prefix) || importPath[len(prefix)] == '/')
+}
+
 func parseMeta(scheme, importPath string, r io.Reader) (map[string]string, error) {
-	var match map[string]string
+	var imports []metaImport
+	var sources []metaSource
 
 	d := xml.NewDecoder(r)
 	d.Strict = false
@@ -263,54 +342,120 @@ metaScan:
 			if strings.EqualFold(t.Name.Local, "body") {
 				break metaScan
 			}
-			if !strings.EqualFold(t.Name.Local, "meta") ||
-				attrValue(t.Attr, "name") != "go-import" {
+			if !strings.EqualFold(t.Name.Local, "meta") {
 				continue metaScan
 			}
 			f := strings.Fields(attrValue(t.Attr, "content"))
-			if len(f) != 3 ||
-				!strings.HasPrefix(importPath, f[0]) ||
-				!(len(importPath) == len(f[0]) || importPath[len(f[0])] == '/') {
-				continue metaScan
+			switch attrValue(t.Attr, "name") {
+			case "go-import":
+				if len(f) == 3 && hasPathPrefix(importPath, f[0]) {
+					imports = append(imports, metaImport{f[0], f[1], f[2]})
+				}
+			case "go-source":
+				if len(f) == 4 && hasPathPrefix(importPath, f[0]) {
+					sources = append(sources, metaSource{f[0], f[1], f[2], f[3]})
+				}
 			}
-			if match != nil {
-				return nil, fmt.Errorf("more than one <meta> found at %s://%s", scheme, importPath)
-			}
-
-			projectRoot, vcs, repo := f[0], f[1], f[2]
+		}
+	}
 
-			repo = strings.TrimSuffix(repo, "."+vcs)
-			i := strings.Index(repo, "://")
-			if i < 0 {
-				return nil, fmt.Errorf("bad repo URL in <meta>")
-			}
-			proto := repo[:i]
-			repo = repo[i+len("://"):]
-
-			match = map[string]string{
-				// Used in getVCSPkg, same as vcsPattern matches.
-				"importPath": importPath,
-				"repo":       repo,
-				"vcs":        vcs,
-				"dir":        importPath[len(projectRoot):],
-
-				// Used in getVCSPkg
-				"scheme": proto,
-
-				// Used in getDynamic.
-				"projectRoot": projectRoot,
-				"projectName": path.Base(projectRoot),
-				"projectURL":  scheme + "://" + projectRoot,
-			}
+	// Choose the longest prefix, then the most preferred VCS.
+	var best *metaImport
+	for i := range imports {
+		im := &imports[i]
+		if vcsPreference[im.vcs] == 0 {
+			continue
+		}
+		if best == nil || len(im.prefix) > len(best.prefix) ||
+			(len(im.prefix) == len(best.prefix) && vcsPreference[im.vcs] < vcsPreference[best.vcs]) {
+			best = im
 		}
 	}
-	if match == nil {
+	if best == nil {
+		if len(imports) > 0 {
+			return nil, fmt.Errorf("no <meta> with supported VCS found at %s://%s", scheme, importPath)
+		}
 		return nil, fmt.Errorf("<meta> not found")
 	}
+
+	projectRoot, vcs, repo := best.prefix, best.vcs, best.repo
+
+	repo = strings.TrimSuffix(repo, "."+vcs)
+	i := strings.Index(repo, "://")
+	if i < 0 {
+		return nil, fmt.Errorf("bad repo URL in <meta>")
+	}
+	proto := repo[:i]
+	repo = repo[i+len("://"):]
+
+	match := map[string]string{
+		// Used in getVCSPkg, same as vcsPattern matches.
+		"importPath": importPath,
+		"repo":       repo,
+		"vcs":        vcs,
+		"dir":        importPath[len(projectRoot):],
+
+		// Used in getVCSPkg
+		"scheme": proto,
+
+		// Used in getDynamic.
+		"projectRoot": projectRoot,
+		"projectName": path.Base(projectRoot),
+		"projectURL":  scheme + "://" + projectRoot,
+	}
+
+	// go-source meta tag should have same prefix as go-import one.
+	for _, src := range sources {
+		if src.prefix == projectRoot {
+			match["sourceHome"] = src.home
+			match["sourceDir"] = src.dir
+			match["sourceFile"] = src.file
+			break
+		}
+	}
 	return match, nil
 }
 
+// fetchMeta returns go-import meta tag of given import path,
+// cached one is used unless it is expired or refresh is required.
+// Expired one is still used when the request fails.
 func fetchMeta(client *http.Client, importPath string) (map[string]string, error) {
+	cached, expired := getCachedMeta(importPath)
+	if cached != nil && !expired && !setting.RefreshMeta {
+		log.Debug("Use cached meta of %s", importPath)
+		return cached, nil
+	}
+
+	match, err := requestMeta(client, importPath)
+	if err != nil {
+		if cached != nil {
+			log.Warn("Use expired meta of %s: %v", importPath, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	// Only cache meta tag fetched from project root itself,
+	// so project root is always verified.
+	if match["projectRoot"] == importPath {
+		if err = saveCachedMeta(match); err != nil {
+			log.Warn("Fail to save meta cache: %v", err)
+		}
+	}
+	return match, nil
+}
+
+// errMetaRequest occurs when go-import meta tag cannot be requested from host.
+type errMetaRequest struct {
+	host string
+	err  error
+}
+
+func (e errMetaRequest) Error() string {
+	return fmt.Sprintf("fail to make request(%s): %v", e.host, e.err)
+}
+
+func requestMeta(client *http.Client, importPath string) (map[string]string, error) {
 	uri := importPath
 	if !strings.Contains(uri, "/") {
 		// Add slash for root of domain.
@@ -319,19 +464,26 @@ func fetchMeta(client *http.Client, importPath string) (map[string]string, error
 	uri = uri + "?go-get=1"
 
 	scheme := "https"
-	resp, err := client.Get(scheme + "://" + uri)
-	if err != nil || resp.StatusCode != 200 {
-		if err == nil {
-			resp.Body.Close()
-		}
+	status, body, err := cachedGet(client, scheme+"://"+uri, setting.RefreshMeta)
+	if err != nil || status != 200 {
 		scheme = "http"
-		resp, err = client.Get(scheme + "://" + uri)
+		_, body, err = cachedGet(client, scheme+"://"+uri, setting.RefreshMeta)
 		if err != nil {
-			return nil, fmt.Errorf("fail to make request(%s): %v", strings.SplitN(importPath, "/", 2)[0], err)
+			return nil, errMetaRequest{strings.SplitN(importPath, "/", 2)[0], err}
 		}
 	}
-	defer resp.Body.Close()
-	return parseMeta(scheme, importPath, resp.Body)
+	return parseMeta(scheme, importPath, bytes.NewReader(body))
+}
+
+// DiscoverRepo returns VCS and repository URL of package by go-import meta tag,
+// upstream source of package is set by go-source meta tag as well.
+func (n *Node) DiscoverRepo() (string, string, error) {
+	match, err := fetchMeta(HttpClient, n.ImportPath)
+	if err != nil {
+		return "", "", err
+	}
+	n.setSource(match)
+	return match["vcs"], match["scheme"] + "://" + match["repo"], nil
 }
 
 func (n *Node) getDynamic(client *http.Client, ctx *cli.Context) ([]string, error) {
@@ -350,8 +502,13 @@ func (n *Node) getDynamic(client *http.Client, ctx *cli.Context) ([]string, erro
 		}
 	}
 
+	n.setSource(match)
 	n.DownloadURL = base.Expand("{repo}{dir}", match)
-	return n.Download(ctx)
+	imports, err := n.Download(ctx)
+	if err != nil && len(n.SourceHome) > 0 {
+		return nil, fmt.Errorf("%v (upstream source: %s)", err, n.SourceURL())
+	}
+	return imports, err
 }
 
 // Download downloads remote package without version control.
@@ -397,26 +554,158 @@ type ApiResponse struct {
 
 func init() {
 	zip.Verbose = false
+	tz.Verbose = false
+}
+
+// isZipFile returns true if given file starts with zip magic number.
+func isZipFile(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 2)
+	if _, err = io.ReadFull(f, magic); err != nil {
+		return false, err
+	}
+	return string(magic) == "PK", nil
+}
+
+// extractArchive extracts zip, tar.gz or tar archive to given path,
+// the format is detected by content of the archive.
+func extractArchive(srcPath, destPath string, fn cae.HookFunc) error {
+	isZip, err := isZipFile(srcPath)
+	if err != nil {
+		return err
+	}
+	if isZip {
+		return zip.ExtractToFunc(srcPath, destPath, fn)
+	}
+	return tz.ExtractToFunc(srcPath, destPath, fn)
+}
+
+// errFinal wraps error that should not be retried.
+type errFinal struct {
+	error
+}
+
+// resumeDownload downloads rest of given URL to file, content of existing
+// partial file is kept and only rest of it is requested by Range header.
+func resumeDownload(url, fileName string) error {
+	var offset int64
+	if fi, err := os.Stat(fileName); err == nil {
+		offset = fi.Size()
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return errFinal{err}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	// downloadArchive retries and resumes failed transfers.
+	resp, err := HttpClient.Do(withoutRetry(req))
+	if err != nil {
+		return fmt.Errorf("fail to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	flag := os.O_WRONLY | os.O_CREATE
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			os.Remove(fileName)
+			return fmt.Errorf("unexpected content range: %s", resp.Header.Get("Content-Range"))
+		}
+		log.Info("Resume download from %d bytes", offset)
+		flag |= os.O_APPEND
+	case http.StatusOK:
+		flag |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Partial file is broken, start over.
+		os.Remove(fileName)
+		return fmt.Errorf("invalid partial archive")
+	default:
+		if canRetry(req, resp, nil) {
+			return fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+		var apiErr ApiError
+		if err = json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+			return errFinal{fmt.Errorf("fail to decode response JSON: %v", err)}
+		}
+		return errFinal{errors.New(apiErr.Error)}
+	}
+
+	os.MkdirAll(path.Dir(fileName), os.ModePerm)
+	fw, err := os.OpenFile(fileName, flag, 0644)
+	if err != nil {
+		return errFinal{err}
+	}
+	defer fw.Close()
+	if _, err = io.Copy(fw, resp.Body); err != nil {
+		return fmt.Errorf("fail to save archive: %v", err)
+	}
+	return nil
+}
+
+// downloadArchive downloads archive of given URL to file,
+// failed transfers are resumed up to HTTP_RETRIES times.
+func downloadArchive(url, fileName string) error {
+	for attempt := 0; ; attempt++ {
+		err := resumeDownload(url, fileName)
+		if err == nil {
+			return nil
+		} else if e, ok := err.(errFinal); ok {
+			return e.error
+		} else if attempt >= setting.HttpRetries {
+			return err
+		}
+
+		wait := backoff(attempt)
+		log.Warn("Download failed, retry in %s: %v", wait, err)
+		time.Sleep(wait)
+	}
 }
 
 // DownloadGopm downloads remote package from gopm registry.
 func (n *Node) DownloadGopm(ctx *cli.Context) error {
+	// Hold locks so concurrent gopm processes do not write same package.
+	repoLock, err := setting.LockRepo(false)
+	if err != nil {
+		return err
+	}
+	defer repoLock.Unlock()
+	pkgLock, err := setting.LockPackage(n.InstallPath)
+	if err != nil {
+		return err
+	}
+	defer pkgLock.Unlock()
+
+	// Other process may have downloaded it while waiting for lock.
+	if n.IsFixed() && n.IsExist() {
+		log.Debug("Package has been downloaded by other process: %s", n.VerString())
+		return nil
+	}
+
 	// Fetch latest version, check if package has been changed.
+	// Cached revision is revalidated when user asks for update.
 	if n.Type == BRANCH && n.IsEmptyVal() {
-		resp, err := http.Get(fmt.Sprintf("%s%s?pkgname=%s",
-			setting.RegistryURL, setting.URL_API_REVISION, n.RootPath))
+		status, body, err := cachedGet(HttpClient, fmt.Sprintf("%s%s?pkgname=%s",
+			setting.RegistryURL, setting.URL_API_REVISION, n.RootPath), ctx.Bool("update"))
 		if err != nil {
 			return fmt.Errorf("fail to make request: %v", err)
 		}
-		if resp.StatusCode != 200 {
+		if status != 200 {
 			var apiErr ApiError
-			if err = json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+			if err = json.Unmarshal(body, &apiErr); err != nil {
 				return fmt.Errorf("fail to decode response JSON: %v", err)
 			}
 			return errors.New(apiErr.Error)
 		}
 		var apiResp ApiResponse
-		if err = json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		if err = json.Unmarshal(body, &apiResp); err != nil {
 			return fmt.Errorf("fail to decode response JSON: %v", err)
 		}
 		if n.Revision == apiResp.Sha {
@@ -426,39 +715,29 @@ func (n *Node) DownloadGopm(ctx *cli.Context) error {
 		n.Revision = apiResp.Sha
 	}
 
-	resp, err := http.Get(fmt.Sprintf("%s%s?pkgname=%s&revision=%s",
-		setting.RegistryURL, setting.URL_API_DOWNLOAD, n.RootPath, n.Value))
-	if err != nil {
-		return fmt.Errorf("fail to make request: %v", err)
+	// Archive of known revision has stable name, so it can be resumed
+	// by next run if download fails.
+	rev := n.Value
+	if len(rev) == 0 {
+		rev = n.Revision
 	}
-	if resp.StatusCode != 200 {
-		var apiErr ApiError
-		if err = json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
-			return fmt.Errorf("fail to decode response JSON: %v", err)
-		}
-		return errors.New(apiErr.Error)
+	if len(rev) == 0 {
+		rev = base.ToStr(time.Now().Nanosecond())
 	}
-
-	tmpPath := path.Join(setting.HomeDir, ".gopm/temp/archive",
-		n.RootPath+"-"+base.ToStr(time.Now().Nanosecond())+".zip")
-	defer os.Remove(tmpPath)
+	tmpPath := path.Join(setting.HomeDir, ".gopm/temp/archive", n.RootPath+"-"+rev+".archive")
 	if setting.Debug {
 		log.Debug("Temp archive path: %s", tmpPath)
 	}
 
-	os.MkdirAll(path.Dir(tmpPath), os.ModePerm)
-	fw, err := os.Create(tmpPath)
-	if err != nil {
+	if err = downloadArchive(fmt.Sprintf("%s%s?pkgname=%s&revision=%s",
+		setting.RegistryURL, setting.URL_API_DOWNLOAD, n.RootPath, n.Value), tmpPath); err != nil {
 		return err
 	}
-	if _, err = io.Copy(fw, resp.Body); err != nil {
-		return fmt.Errorf("fail to save archive: %v", err)
-	}
-	fw.Close()
+	defer os.Remove(tmpPath)
 
-	// Remove old files.
-	os.RemoveAll(n.InstallPath)
-	os.MkdirAll(path.Dir(n.InstallPath), os.ModePerm)
+	// Extract into staging directory and move into place only when complete.
+	stagePath := n.InstallPath + ".staging-" + base.ToStr(time.Now().Nanosecond())
+	defer os.RemoveAll(stagePath)
 
 	var rootDir string
 	var extractFn = func(fullName string, fi os.FileInfo) error {
@@ -468,10 +747,17 @@ func (n *Node) DownloadGopm(ctx *cli.Context) error {
 		return nil
 	}
 
-	if err := zip.ExtractToFunc(tmpPath, path.Dir(n.InstallPath), extractFn); err != nil {
+	if err := extractArchive(tmpPath, stagePath, extractFn); err != nil {
+		switch err.(type) {
+		case cae.ErrUnsafeEntry, cae.ErrExceedLimit:
+			return fmt.Errorf("reject malicious archive: %v", err)
+		}
 		return fmt.Errorf("fail to extract archive: %v", err)
-	} else if err = os.Rename(path.Join(path.Dir(n.InstallPath), rootDir),
-		n.InstallPath); err != nil {
+	}
+
+	// Remove old files.
+	os.RemoveAll(n.InstallPath)
+	if err = os.Rename(path.Join(stagePath, rootDir), n.InstallPath); err != nil {
 		return fmt.Errorf("fail to rename directory: %v", err)
 	}
 	return nil
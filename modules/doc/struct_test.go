@@ -0,0 +1,119 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package doc
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseMeta(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		importPath string
+		html       string
+		expect     map[string]string // Expected subset of match.
+		errStr     string
+	}{
+		{
+			"single go-import",
+			"example.com/foo/bar",
+			`<html><head><meta name="go-import" content="example.com/foo git https://git.example.com/foo.git"></head></html>`,
+			map[string]string{
+				"projectRoot": "example.com/foo", "vcs": "git", "repo": "git.example.com/foo",
+				"scheme": "https", "dir": "/bar", "projectName": "foo",
+			},
+			"",
+		},
+		{
+			"longest prefix wins",
+			"example.com/foo/bar",
+			`<head>
+<meta name="go-import" content="example.com/foo git https://example.com/foo">
+<meta name="go-import" content="example.com/foo/bar hg https://example.com/bar">
+</head>`,
+			map[string]string{"projectRoot": "example.com/foo/bar", "vcs": "hg", "dir": ""},
+			"",
+		},
+		{
+			"preferred VCS on same prefix and mod is ignored",
+			"example.com/foo",
+			`<meta name="go-import" content="example.com/foo mod https://proxy.example.com">
+<meta name="go-import" content="example.com/foo hg https://example.com/foo">
+<meta name="go-import" content="example.com/foo git https://example.com/foo">`,
+			map[string]string{"vcs": "git"},
+			"",
+		},
+		{
+			"prefix is not a path parent",
+			"example.com/foobar",
+			`<meta name="go-import" content="example.com/foo git https://example.com/foo">`,
+			nil,
+			"<meta> not found",
+		},
+		{
+			"only unsupported VCS",
+			"example.com/foo",
+			`<meta name="go-import" content="example.com/foo mod https://proxy.example.com">`,
+			nil,
+			"no <meta> with supported VCS",
+		},
+		{
+			"meta in body is ignored",
+			"example.com/foo",
+			`<head></head><body><meta name="go-import" content="example.com/foo git https://example.com/foo"></body>`,
+			nil,
+			"<meta> not found",
+		},
+		{
+			"go-source with same prefix",
+			"example.com/foo",
+			`<meta name="go-import" content="example.com/foo git https://example.com/foo">
+<meta name="go-source" content="example.com/other _ {/dir} {/dir}/{file}">
+<meta name="go-source" content="example.com/foo https://example.com/foo https://example.com/foo/tree{/dir} https://example.com/foo/blob{/dir}/{file}">`,
+			map[string]string{
+				"sourceHome": "https://example.com/foo",
+				"sourceDir":  "https://example.com/foo/tree{/dir}",
+				"sourceFile": "https://example.com/foo/blob{/dir}/{file}",
+			},
+			"",
+		},
+		{
+			"bad repo URL",
+			"example.com/foo",
+			`<meta name="go-import" content="example.com/foo git example.com/foo">`,
+			nil,
+			"bad repo URL",
+		},
+	}
+
+	for _, tc := range testCases {
+		match, err := parseMeta("https", tc.importPath, strings.NewReader(tc.html))
+		if len(tc.errStr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+				t.Errorf("%s: expect error contains '%s', got %v", tc.desc, tc.errStr, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		for key, val := range tc.expect {
+			if match[key] != val {
+				t.Errorf("%s: expect %s = '%s', got '%s'", tc.desc, key, val, match[key])
+			}
+		}
+	}
+}
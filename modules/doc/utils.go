@@ -45,32 +45,69 @@ func ParseTarget(target string) string {
 	return "."
 }
 
-func joinPath(name string, num int) string {
-	subdirs := strings.Split(name, "/")
-	if len(subdirs) > num {
-		return strings.Join(subdirs[:num], "/")
-	}
-	return name
-}
-
 var gopkgPathPattern = regexp.MustCompile(`^/(?:([a-zA-Z0-9][-a-zA-Z0-9]+)/)?([a-zA-Z][-.a-zA-Z0-9]*)\.((?:v0|v[1-9][0-9]*)(?:\.0|\.[1-9][0-9]*){0,2})(?:\.git)?((?:/[a-zA-Z0-9][-.a-zA-Z0-9]*)*)$`)
 
-// GetRootPath returns project root path.
-func GetRootPath(name string) string {
-	for prefix, num := range setting.RootPathPairs {
-		if strings.HasPrefix(name, prefix) {
-			return joinPath(name, num)
+// discoverRootPath discovers project root path of given import path
+// by its go-import meta tag and saves it or failure for later runs.
+// It only makes requests when setting.DiscoverRootPath is set.
+// Failure is saved for the host if it cannot be reached,
+// so other packages of the host are not requested again.
+func discoverRootPath(name string) (string, bool) {
+	if !setting.DiscoverRootPath || len(setting.RootPathsFile) == 0 ||
+		setting.IsRootPathMiss(name) || IsGoRepoPath(name) || !base.IsValidRemotePath(name) {
+		return "", false
+	}
+
+	match, err := fetchMeta(HttpClient, name)
+	if err != nil {
+		log.Debug("Fail to discover root path of %s: %v", name, err)
+		miss := name
+		if e, ok := err.(errMetaRequest); ok {
+			miss = e.host
 		}
+		if err = setting.SaveRootPathMiss(miss); err != nil {
+			log.Warn("Fail to save root path: %v", err)
+		}
+		return "", false
+	}
+
+	root := match["projectRoot"]
+	log.Debug("Discovered root path of %s: %s", name, root)
+	if err = setting.SaveRootPath(root); err != nil {
+		log.Warn("Fail to save root path: %v", err)
+	}
+	return root, true
+}
+
+// localRootPath returns project root path by rules in gopmfile and gopm.ini,
+// or root paths discovered before, without any network request.
+func localRootPath(name string) (string, bool) {
+	if root, ok := setting.MatchRootPath(name); ok {
+		return root, true
 	}
 
 	if strings.HasPrefix(name, "gopkg.in") {
 		m := gopkgPathPattern.FindStringSubmatch(strings.TrimPrefix(name, "gopkg.in"))
 		if m == nil {
-			return name
+			return name, true
 		}
 		user := m[1]
 		repo := m[2]
-		return path.Join("gopkg.in", user, repo+"."+m[3])
+		return path.Join("gopkg.in", user, repo+"."+m[3]), true
+	}
+
+	return setting.DiscoveredRootPath(name)
+}
+
+// GetRootPath returns project root path.
+// Rules in gopmfile and gopm.ini are checked first,
+// then root paths discovered by go-import meta tags.
+func GetRootPath(name string) string {
+	if root, ok := localRootPath(name); ok {
+		return root
+	}
+	if root, ok := discoverRootPath(name); ok {
+		return root
 	}
 	return name
 }
@@ -60,9 +60,11 @@ type ConfigFile struct {
 	sectionList []string            // Section name list.
 	keyList     map[string][]string // Section -> Key name list
 
-	sectionComments map[string]string            // Sections comments.
-	keyComments     map[string]map[string]string // Keys comments.
-	BlockMode       bool                         // Indicates whether use lock or not.
+	sectionComments map[string]string              // Sections comments.
+	keyComments     map[string]map[string]string   // Keys comments.
+	syntax          *syntaxTree                    // Raw lines for round-trip editing.
+	keyPos          map[string]map[string]position // Keys positions.
+	BlockMode       bool                           // Indicates whether use lock or not.
 }
 
 // newConfigFile creates an empty configuration representation.
@@ -73,6 +75,7 @@ func newConfigFile(fileNames []string) *ConfigFile {
 	c.keyList = make(map[string][]string)
 	c.sectionComments = make(map[string]string)
 	c.keyComments = make(map[string]map[string]string)
+	c.keyPos = make(map[string]map[string]position)
 	c.BlockMode = true
 	return c
 }
@@ -98,15 +101,24 @@ func (c *ConfigFile) SetValue(section, key, value string) bool {
 		c.data[section] = make(map[string]string)
 		// Append section to list.
 		c.sectionList = append(c.sectionList, section)
+		// Non-default section has a blank key as section keeper,
+		// as it has when read from file.
+		if section != DEFAULT_SECTION && key != " " {
+			c.data[section][" "] = " "
+			c.keyList[section] = append(c.keyList[section], " ")
+		}
 	}
 
 	// Check if key exists.
-	_, ok := c.data[section][key]
+	old, ok := c.data[section][key]
 	c.data[section][key] = value
 	if !ok {
 		// If not exists, append to key list.
 		c.keyList[section] = append(c.keyList[section], key)
 	}
+	if c.syntax != nil && key != " " && !(ok && old == value) {
+		c.syntax.setValue(section, key, value)
+	}
 	return !ok
 }
 
@@ -139,6 +151,9 @@ func (c *ConfigFile) DeleteKey(section, key string) bool {
 		}
 		// Remove from key list.
 		c.keyList[section] = append(c.keyList[section][:i], c.keyList[section][i+1:]...)
+		if c.syntax != nil {
+			c.syntax.deleteKey(section, key)
+		}
 		return true
 	}
 	return false
@@ -149,6 +164,7 @@ func (c *ConfigFile) DeleteKey(section, key string) bool {
 // (see e.g. %(google)s example in the GoConfig_test.go),
 // then String does this unfolding automatically, up to
 // _DEPTH_VALUES number of iterations.
+// Environment variables in form of ${NAME} are expanded as well.
 // It returns an error and empty string value if the section does not exist,
 // or key does not exist in DEFAULT and current sections.
 func (c *ConfigFile) GetValue(section, key string) (string, error) {
@@ -205,7 +221,7 @@ func (c *ConfigFile) GetValue(section, key string) (string, error) {
 		// Substitute by new value and take off leading '%(' and trailing ')s'.
 		value = strings.Replace(value, vr, nvalue, -1)
 	}
-	return value, nil
+	return c.expandEnv(section, key, value)
 }
 
 // Bool returns bool type value.
@@ -396,6 +412,9 @@ func (c *ConfigFile) DeleteSection(section string) bool {
 	}
 	// Remove from section list.
 	c.sectionList = append(c.sectionList[:i], c.sectionList[i+1:]...)
+	if c.syntax != nil {
+		c.syntax.deleteSection(section)
+	}
 	return true
 }
 
@@ -435,6 +454,9 @@ func (c *ConfigFile) SetSectionComments(section, comments string) bool {
 		if _, ok := c.sectionComments[section]; ok {
 			delete(c.sectionComments, section)
 		}
+		if c.syntax != nil {
+			c.syntax.setComments(section, "", "")
+		}
 
 		// Not exists can be seen as remove.
 		return true
@@ -446,6 +468,9 @@ func (c *ConfigFile) SetSectionComments(section, comments string) bool {
 		comments = "; " + comments
 	}
 	c.sectionComments[section] = comments
+	if c.syntax != nil {
+		c.syntax.setComments(section, "", comments)
+	}
 	return !ok
 }
 
@@ -466,6 +491,9 @@ func (c *ConfigFile) SetKeyComments(section, key, comments string) bool {
 			if _, ok := c.keyComments[section][key]; ok {
 				delete(c.keyComments[section], key)
 			}
+			if c.syntax != nil {
+				c.syntax.setComments(section, key, "")
+			}
 
 			// Not exists can be seen as remove.
 			return true
@@ -486,6 +514,9 @@ func (c *ConfigFile) SetKeyComments(section, key, comments string) bool {
 		comments = "; " + comments
 	}
 	c.keyComments[section][key] = comments
+	if c.syntax != nil {
+		c.syntax.setComments(section, key, comments)
+	}
 	return !ok
 }
 
@@ -0,0 +1,48 @@
+// Copyright 2013 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package goconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_SetValue_KeyList(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		data    string
+		section string
+		keys    []string // Keys to set in order.
+		expect  []string
+	}{
+		{"new section", "", "deps", []string{"a", "b"}, []string{"a", "b"}},
+		{"existing section", "[deps]\na = 1\n", "deps", []string{"b", "a"}, []string{"a", "b"}},
+		{"empty existing section", "[deps]\n", "deps", []string{"a"}, []string{"a"}},
+		{"default section", "", DEFAULT_SECTION, []string{"a", "b"}, []string{"a", "b"}},
+	}
+
+	for _, tc := range testCases {
+		c, err := LoadFromData([]byte(tc.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		for _, key := range tc.keys {
+			c.SetValue(tc.section, key, "v")
+		}
+		if keys := c.GetKeyList(tc.section); !reflect.DeepEqual(keys, tc.expect) {
+			t.Errorf("%s: expect keys %v, got %v", tc.desc, tc.expect, keys)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2013 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package goconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Section name of include directives.
+const _INCLUDE_SECTION = "include"
+
+// Environment variable pattern: ${NAME}, use $${NAME} for literal.
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// An include represents an include directive in configuration file.
+type include struct {
+	fileName string
+	pos      position
+}
+
+// parseIncludes returns files included by given configuration file.
+// Directives are 'include = path' before any section or
+// values of keys in '[include]' section, relative paths are
+// resolved against directory of the file.
+func parseIncludes(fileName string, data []byte) ([]include, error) {
+	c := newConfigFile(nil)
+	if err := c.read(bytes.NewReader(data), fileName); err != nil {
+		return nil, err
+	}
+
+	keys := make([][2]string, 0, 2)
+	if _, ok := c.data[DEFAULT_SECTION][_INCLUDE_SECTION]; ok {
+		keys = append(keys, [2]string{DEFAULT_SECTION, _INCLUDE_SECTION})
+	}
+	for _, key := range c.GetKeyList(_INCLUDE_SECTION) {
+		keys = append(keys, [2]string{_INCLUDE_SECTION, key})
+	}
+
+	includes := make([]include, 0, len(keys))
+	for _, k := range keys {
+		pos := c.position(k[0], k[1])
+		name, err := c.expandEnv(k[0], k[1], c.data[k[0]][k[1]])
+		if err != nil {
+			return nil, err
+		} else if len(name) == 0 {
+			return nil, posError{pos, "empty include path"}
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(fileName), name)
+		}
+		includes = append(includes, include{name, pos})
+	}
+	return includes, nil
+}
+
+// sameFile returns true if two names refer to the same file.
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
+
+// setPosition records where key is defined.
+func (c *ConfigFile) setPosition(section, key string, pos position) {
+	if _, ok := c.keyPos[section]; !ok {
+		c.keyPos[section] = make(map[string]position)
+	}
+	c.keyPos[section][key] = pos
+}
+
+// SetPosition records file name and line number where key is defined,
+// or where section header is when key is empty. It is used by parsers
+// of other formats to report problems with positions.
+func (c *ConfigFile) SetPosition(section, key, fileName string, line int) {
+	// Blank section name represents DEFAULT section.
+	if len(section) == 0 {
+		section = DEFAULT_SECTION
+	}
+	c.setPosition(section, key, position{fileName, line})
+}
+
+// position returns where key is defined.
+func (c *ConfigFile) position(section, key string) position {
+	return c.keyPos[section][key]
+}
+
+// Position returns file name and line number where key is defined,
+// or where section header is when key is empty.
+// Line number is 0 if key is not read from file.
+func (c *ConfigFile) Position(section, key string) (string, int) {
+	// Blank section name represents DEFAULT section.
+	if len(section) == 0 {
+		section = DEFAULT_SECTION
+	}
+	pos := c.position(section, key)
+	return pos.fileName, pos.line
+}
+
+// expandEnv replaces ${NAME} in value with environment variables,
+// '$$' is a literal '$' only in values refer to any variable.
+// It returns error with position if any variable is undefined.
+func (c *ConfigFile) expandEnv(section, key, value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var err error
+	value = envPattern.ReplaceAllStringFunc(value, func(s string) string {
+		if s == "$$" {
+			return "$"
+		}
+		name := s[2 : len(s)-1]
+		val, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = posError{c.position(section, key),
+				fmt.Sprintf("undefined environment variable '%s' in %s", name, key)}
+		}
+		return val
+	})
+	return value, err
+}
@@ -0,0 +1,139 @@
+// Copyright 2013 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package goconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFiles writes files with given names and contents to a temporary directory.
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "goconfig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_Include(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		files  map[string]string
+		values map[string]string // 'section.key' to expected value.
+		errStr string
+	}{
+		{
+			"include before any section",
+			map[string]string{
+				"app.ini":  "include = base.ini\n\n[http]\nPROXY = app\n",
+				"base.ini": "[http]\nPROXY = base\nTIMEOUT = 10\n",
+			},
+			map[string]string{"http.PROXY": "app", "http.TIMEOUT": "10"},
+			"",
+		},
+		{
+			"include section and nested includes",
+			map[string]string{
+				"app.ini":    "[include]\n- = base.ini\n\n[deps]\na = 1\n",
+				"base.ini":   "include = common.ini\n[deps]\nb = 2\n",
+				"common.ini": "[deps]\na = 0\nc = 3\n",
+			},
+			map[string]string{"deps.a": "1", "deps.b": "2", "deps.c": "3"},
+			"",
+		},
+		{
+			"include cycle",
+			map[string]string{
+				"app.ini":  "include = base.ini\n",
+				"base.ini": "include = app.ini\n",
+			},
+			nil,
+			"include cycle",
+		},
+		{
+			"missing included file",
+			map[string]string{"app.ini": "include = none.ini\n"},
+			nil,
+			"app.ini:1: fail to include",
+		},
+	}
+
+	for _, tc := range testCases {
+		dir := writeTestFiles(t, tc.files)
+		defer os.RemoveAll(dir)
+
+		c, err := LoadConfigFile(filepath.Join(dir, "app.ini"))
+		if len(tc.errStr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+				t.Errorf("%s: expect error contains '%s', got %v", tc.desc, tc.errStr, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+
+		for name, expect := range tc.values {
+			infos := strings.SplitN(name, ".", 2)
+			if val, err := c.GetValue(infos[0], infos[1]); err != nil || val != expect {
+				t.Errorf("%s: expect %s = '%s', got '%s' (%v)", tc.desc, name, expect, val, err)
+			}
+		}
+	}
+}
+
+func Test_ExpandEnv(t *testing.T) {
+	os.Setenv("GOCONFIG_TEST_HOME", "/home/gopm")
+	os.Unsetenv("GOCONFIG_TEST_UNDEFINED")
+
+	c, err := LoadFromData([]byte(`[env]
+path = ${GOCONFIG_TEST_HOME}/bin
+escaped = $${GOCONFIG_TEST_HOME} and $$ and ${GOCONFIG_TEST_HOME}
+price = $$5
+undefined = ${GOCONFIG_TEST_UNDEFINED}/bin
+`))
+	if err != nil {
+		t.Fatalf("undefined variable should not fail loading: %v", err)
+	}
+
+	testCases := []struct {
+		key    string
+		expect string
+		isErr  bool
+	}{
+		{"path", "/home/gopm/bin", false},
+		{"escaped", "${GOCONFIG_TEST_HOME} and $ and /home/gopm", false},
+		{"price", "$$5", false},
+		{"undefined", "/bin", true},
+	}
+	for _, tc := range testCases {
+		val, err := c.GetValue("env", tc.key)
+		if val != tc.expect {
+			t.Errorf("%s: expect '%s', got '%s'", tc.key, tc.expect, val)
+		}
+		if (err != nil) != tc.isErr {
+			t.Errorf("%s: expect error %v, got %v", tc.key, tc.isErr, err)
+		}
+	}
+}
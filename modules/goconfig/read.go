@@ -28,16 +28,29 @@ import (
 
 // Read reads an io.Reader and returns a configuration representation.
 // This representation can be queried with GetValue.
-func (c *ConfigFile) read(reader io.Reader) (err error) {
+// File name is used to record positions of keys.
+func (c *ConfigFile) read(reader io.Reader, fileName string) (err error) {
 	buf := bufio.NewReader(reader)
 
+	// Detach syntax tree so changes during parsing do not edit it,
+	// lines of this file are appended to it at the end.
+	tree := c.syntax
+	if tree == nil {
+		tree = newSyntaxTree()
+	}
+	c.syntax = nil
+	defer func() { c.syntax = tree }()
+
 	count := 1 // Counter for auto increment.
 	// Current section name.
 	section := DEFAULT_SECTION
 	var comments string
+	lineNum := 0
 	// Parse line-by-line
 	for {
 		line, err := buf.ReadString('\n')
+		lineNum++
+		raw, eol := splitLineBreak(line)
 		line = strings.TrimSpace(line)
 		lineLengh := len(line) //[SWH|+]
 		if err != nil {
@@ -48,15 +61,23 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 			// Reached end of file, if nothing to read then break,
 			// otherwise handle the last line.
 			if lineLengh == 0 {
+				if len(raw) > 0 {
+					tree.append(&syntaxLine{kind: lineBlank, raw: raw, section: section})
+				}
 				break
 			}
 		}
+		sl := &syntaxLine{raw: raw, eol: eol, section: section}
 
 		// switch written for readability (not performance)
 		switch {
 		case lineLengh == 0: // Empty line
+			sl.kind = lineBlank
+			tree.append(sl)
 			continue
 		case line[0] == '#' || line[0] == ';': // Comment
+			sl.kind = lineComment
+			tree.append(sl)
 			// Append comments
 			if len(comments) == 0 {
 				comments = line
@@ -67,6 +88,10 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 		case line[0] == '[' && line[lineLengh-1] == ']': // New sction.
 			// Get section name.
 			section = strings.TrimSpace(line[1 : lineLengh-1])
+			sl.kind = lineSection
+			sl.section = section
+			tree.append(sl)
+			c.setPosition(section, "", position{fileName, lineNum})
 			// Set section comments and empty if it has comments.
 			if len(comments) > 0 {
 				c.SetSectionComments(section, comments)
@@ -78,7 +103,7 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 			count = 1
 			continue
 		case section == "": // No section defined so far
-			return readError{ErrBlankSectionName, line}
+			return readError{ErrBlankSectionName, line, position{fileName, lineNum}}
 		default: // Other alternatives
 			var (
 				i        int
@@ -101,19 +126,19 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 				qLen := len(keyQuote)
 				pos := strings.Index(line[qLen:], keyQuote)
 				if pos == -1 {
-					return readError{ErrCouldNotParse, line}
+					return readError{ErrCouldNotParse, line, position{fileName, lineNum}}
 				}
 				pos = pos + qLen
 				i = strings.IndexAny(line[pos:], "=:")
 				if i <= 0 {
-					return readError{ErrCouldNotParse, line}
+					return readError{ErrCouldNotParse, line, position{fileName, lineNum}}
 				}
 				i = i + pos
 				key = line[qLen:pos] //保留引号内的两端的空格
 			} else {
 				i = strings.IndexAny(line, "=:")
 				if i <= 0 {
-					return readError{ErrCouldNotParse, line}
+					return readError{ErrCouldNotParse, line, position{fileName, lineNum}}
 				}
 				key = strings.TrimSpace(line[0:i])
 			}
@@ -127,6 +152,12 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 
 			//[SWH|+]:支持引号包围起来的字串
 			lineRight := strings.TrimSpace(line[i+1:])
+
+			// Record where value starts in raw line.
+			sl.kind = lineKey
+			sl.key = key
+			sl.valueOffset = len(raw) - len(strings.TrimLeft(raw, " \t")) + len(line) - len(lineRight)
+			tree.append(sl)
 			lineRightLength := len(lineRight)
 			firstChar := ""
 			if lineRightLength >= 2 {
@@ -141,7 +172,7 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 				qLen := len(valQuote)
 				pos := strings.LastIndex(lineRight[qLen:], valQuote)
 				if pos == -1 {
-					return readError{ErrCouldNotParse, line}
+					return readError{ErrCouldNotParse, line, position{fileName, lineNum}}
 				}
 				pos = pos + qLen
 				value = lineRight[qLen:pos]
@@ -151,6 +182,7 @@ func (c *ConfigFile) read(reader io.Reader) (err error) {
 			//[SWH|+];
 
 			c.SetValue(section, key, value)
+			c.setPosition(section, key, position{fileName, lineNum})
 			// Set key comments and empty if it has comments.
 			if len(comments) > 0 {
 				c.SetKeyComments(section, key, comments)
@@ -177,18 +209,50 @@ func LoadFromData(data []byte) (c *ConfigFile, err error) {
 	}
 
 	c = newConfigFile([]string{tmpName})
-	err = c.read(bytes.NewBuffer(data))
+	err = c.read(bytes.NewBuffer(data), "")
 	return c, err
 }
 
-func (c *ConfigFile) loadFile(fileName string) (err error) {
-	f, err := os.Open(fileName)
+// loadFile loads given file and files it includes,
+// include stack is used to detect cycles.
+func (c *ConfigFile) loadFile(fileName string, includeStack ...string) (err error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	return c.loadData(fileName, data, includeStack...)
+}
+
+// loadData loads given data as content of file and files it includes.
+func (c *ConfigFile) loadData(fileName string, data []byte, includeStack ...string) (err error) {
+	// Load included files first, so current file can override their values.
+	includes, err := parseIncludes(fileName, data)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	includeStack = append(includeStack, fileName)
+	for _, inc := range includes {
+		for i, name := range includeStack {
+			if sameFile(name, inc.fileName) {
+				return posError{inc.pos, fmt.Sprintf("include cycle: %s -> %s",
+					strings.Join(includeStack[i:], " -> "), inc.fileName)}
+			}
+		}
+
+		// Included lines do not belong to current file when save.
+		tree := c.syntax
+		c.syntax = nil
+		err = c.loadFile(inc.fileName, includeStack...)
+		c.syntax = tree
+		if err != nil {
+			if _, ok := err.(posError); ok {
+				return err
+			}
+			return posError{inc.pos, fmt.Sprintf("fail to include %s: %v", inc.fileName, err)}
+		}
+	}
 
-	return c.read(f)
+	return c.read(bytes.NewReader(data), fileName)
 }
 
 // LoadConfigFile reads a file and returns a new configuration representation.
@@ -208,7 +272,16 @@ func LoadConfigFile(fileName string, moreFiles ...string) (c *ConfigFile, err er
 			return nil, err
 		}
 	}
+	return c, nil
+}
 
+// LoadFromFileData works like LoadConfigFile but uses given data
+// as content of the file, so the file is not read again.
+func LoadFromFileData(fileName string, data []byte) (c *ConfigFile, err error) {
+	c = newConfigFile([]string{fileName})
+	if err = c.loadData(fileName, data); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -237,15 +310,45 @@ func (c *ConfigFile) AppendFiles(files ...string) error {
 type readError struct {
 	Reason  int
 	Content string // Line content
+	pos     position
 }
 
 // Error implement Error interface.
 func (err readError) Error() string {
+	msg := "invalid read error"
 	switch err.Reason {
 	case ErrBlankSectionName:
-		return "empty section name not allowed"
+		msg = "empty section name not allowed"
 	case ErrCouldNotParse:
-		return fmt.Sprintf("could not parse line: %s", string(err.Content))
+		msg = fmt.Sprintf("could not parse line: %s", string(err.Content))
+	}
+	return posError{err.pos, msg}.Error()
+}
+
+// A position represents location of a key in configuration file.
+type position struct {
+	fileName string
+	line     int
+}
+
+func (p position) String() string {
+	if len(p.fileName) == 0 {
+		return fmt.Sprintf("line %d", p.line)
+	}
+	return fmt.Sprintf("%s:%d", p.fileName, p.line)
+}
+
+// posError occurs with position in configuration file.
+type posError struct {
+	pos position
+	msg string
+}
+
+// Error implement Error interface.
+func (err posError) Error() string {
+	// Key is not from file.
+	if err.pos.line == 0 {
+		return err.msg
 	}
-	return "invalid read error"
+	return err.pos.String() + ": " + err.msg
 }
@@ -0,0 +1,276 @@
+// Copyright 2013 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package goconfig
+
+import (
+	"bytes"
+	"strings"
+)
+
+type lineKind int
+
+const (
+	lineBlank lineKind = iota
+	lineComment
+	lineSection
+	lineKey
+)
+
+// A syntaxLine represents a raw line of configuration file.
+type syntaxLine struct {
+	kind        lineKind
+	raw         string // Line content without line break.
+	eol         string // Line break of the line, empty for last line without it.
+	section     string // Section that line belongs to.
+	key         string
+	valueOffset int // Offset of value in raw content for key line.
+}
+
+// A syntaxTree keeps raw lines of configuration file,
+// so programmatic changes only affect related lines
+// and rest of file stays byte-identical when save.
+type syntaxTree struct {
+	lines []*syntaxLine
+	eol   string // Line break used by new lines.
+}
+
+func newSyntaxTree() *syntaxTree {
+	return &syntaxTree{eol: LineBreak}
+}
+
+// splitLineBreak splits raw line into content and line break.
+func splitLineBreak(raw string) (string, string) {
+	switch {
+	case strings.HasSuffix(raw, "\r\n"):
+		return raw[:len(raw)-2], "\r\n"
+	case strings.HasSuffix(raw, "\n"):
+		return raw[:len(raw)-1], "\n"
+	}
+	return raw, ""
+}
+
+// append adds a parsed line to the end of tree.
+func (t *syntaxTree) append(l *syntaxLine) {
+	if len(t.lines) == 0 && len(l.eol) > 0 {
+		t.eol = l.eol
+	}
+	t.lines = append(t.lines, l)
+}
+
+// insert inserts lines at given index.
+func (t *syntaxTree) insert(idx int, lines ...*syntaxLine) {
+	for _, l := range lines {
+		l.eol = t.eol
+	}
+	// Keep file without line break at the end as it is.
+	if idx == len(t.lines) && idx > 0 && len(t.lines[idx-1].eol) == 0 {
+		t.lines[idx-1].eol = t.eol
+		lines[len(lines)-1].eol = ""
+	}
+
+	tail := append([]*syntaxLine{}, t.lines[idx:]...)
+	t.lines = append(append(t.lines[:idx], lines...), tail...)
+}
+
+// remove removes lines in range [start, end).
+func (t *syntaxTree) remove(start, end int) {
+	// Keep file without line break at the end as it is.
+	if end == len(t.lines) && start > 0 && len(t.lines[end-1].eol) == 0 {
+		t.lines[start-1].eol = ""
+	}
+	t.lines = append(t.lines[:start], t.lines[end:]...)
+}
+
+// commentStart returns start index of comment lines right above given index.
+func (t *syntaxTree) commentStart(idx int) int {
+	for idx > 0 && t.lines[idx-1].kind == lineComment {
+		idx--
+	}
+	return idx
+}
+
+// indexKey returns index of last line of given key, or -1 if not found.
+func (t *syntaxTree) indexKey(section, key string) int {
+	for i := len(t.lines) - 1; i >= 0; i-- {
+		l := t.lines[i]
+		if l.kind == lineKey && l.section == section && l.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexSection returns index of first header of given section, or -1 if not found.
+func (t *syntaxTree) indexSection(section string) int {
+	for i, l := range t.lines {
+		if l.kind == lineSection && l.section == section {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatKey returns key name in the form can be read back.
+func formatKey(key string) string {
+	// Check if it's auto increment.
+	if key[0] == '#' {
+		return "-"
+	}
+	//[SWH|+]:支持键名包含等号和冒号
+	if strings.Contains(key, `=`) || strings.Contains(key, `:`) {
+		if strings.Contains(key, "`") {
+			if strings.Contains(key, `"`) {
+				return `"""` + key + `"""`
+			}
+			return `"` + key + `"`
+		}
+		return "`" + key + "`"
+	}
+	return key
+}
+
+// formatValue returns value in the form can be read back.
+func formatValue(value string) string {
+	// In case key value contains "`" or "\"".
+	if strings.Contains(value, "`") {
+		if strings.Contains(value, `"`) {
+			return `"""` + value + `"""`
+		}
+		return `"` + value + `"`
+	}
+	return value
+}
+
+func equalSign() string {
+	if PrettyFormat {
+		return " = "
+	}
+	return "="
+}
+
+// setValue changes value of existed key line, or adds a new key line
+// to the end of section.
+func (t *syntaxTree) setValue(section, key, value string) {
+	if idx := t.indexKey(section, key); idx > -1 {
+		l := t.lines[idx]
+		prefix := l.raw[:l.valueOffset]
+		if PrettyFormat && len(value) > 0 && !strings.HasSuffix(prefix, " ") &&
+			!strings.HasSuffix(prefix, "\t") {
+			prefix += " "
+		}
+		l.raw = prefix + formatValue(value)
+		return
+	}
+
+	// Find last key or header of the section.
+	last := -1
+	for i, l := range t.lines {
+		if l.section == section && (l.kind == lineKey || l.kind == lineSection) {
+			last = i
+		}
+	}
+
+	l := &syntaxLine{kind: lineKey, section: section, key: key}
+	indent := ""
+	if last > -1 && t.lines[last].kind == lineKey {
+		raw := t.lines[last].raw
+		indent = raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+	}
+	l.raw = indent + formatKey(key) + equalSign()
+	l.valueOffset = len(l.raw)
+	l.raw += formatValue(value)
+
+	switch {
+	case last > -1:
+		t.insert(last+1, l)
+	case section == DEFAULT_SECTION:
+		t.insert(0, l)
+	default:
+		lines := make([]*syntaxLine, 0, 3)
+		if n := len(t.lines); n > 0 && t.lines[n-1].kind != lineBlank {
+			lines = append(lines, &syntaxLine{kind: lineBlank, section: section})
+		}
+		lines = append(lines, &syntaxLine{kind: lineSection, raw: "[" + section + "]", section: section}, l)
+		t.insert(len(t.lines), lines...)
+	}
+}
+
+// deleteKey removes all lines of given key and their comments.
+func (t *syntaxTree) deleteKey(section, key string) {
+	for idx := t.indexKey(section, key); idx > -1; idx = t.indexKey(section, key) {
+		t.remove(t.commentStart(idx), idx+1)
+	}
+}
+
+// deleteSection removes all lines of given section and their comments.
+func (t *syntaxTree) deleteSection(section string) {
+	if section == DEFAULT_SECTION {
+		for i := len(t.lines) - 1; i >= 0; i-- {
+			if l := t.lines[i]; l.kind == lineKey && l.section == section {
+				t.remove(t.commentStart(i), i+1)
+			}
+		}
+		return
+	}
+
+	for start := t.indexSection(section); start > -1; start = t.indexSection(section) {
+		end := start + 1
+		for end < len(t.lines) && t.lines[end].kind != lineSection {
+			end++
+		}
+		// Comments right above next section belong to it.
+		if end < len(t.lines) {
+			end = t.commentStart(end)
+		}
+		t.remove(t.commentStart(start), end)
+	}
+}
+
+// setComments replaces comment lines right above given key,
+// or section header when key is empty.
+func (t *syntaxTree) setComments(section, key, comments string) {
+	idx := -1
+	if len(key) == 0 {
+		idx = t.indexSection(section)
+	} else {
+		idx = t.indexKey(section, key)
+	}
+	if idx == -1 {
+		return
+	}
+
+	start := t.commentStart(idx)
+	t.remove(start, idx)
+	if len(comments) == 0 {
+		return
+	}
+
+	parts := strings.Split(strings.Replace(comments, "\r\n", "\n", -1), "\n")
+	lines := make([]*syntaxLine, len(parts))
+	for i, part := range parts {
+		lines[i] = &syntaxLine{kind: lineComment, raw: part, section: t.lines[start].section}
+	}
+	t.insert(start, lines...)
+}
+
+// bytes returns content of configuration file.
+func (t *syntaxTree) bytes() []byte {
+	buf := new(bytes.Buffer)
+	for _, l := range t.lines {
+		buf.WriteString(l.raw)
+		buf.WriteString(l.eol)
+	}
+	return buf.Bytes()
+}
@@ -16,19 +16,20 @@ package goconfig
 
 import (
 	"bytes"
-	"os"
 	"strings"
+
+	"github.com/gpmgo/gopm/modules/base"
 )
 
 // Write spaces around "=" to look better.
 var PrettyFormat = true
 
-// SaveConfigFile writes configuration file to local file system
+// SaveConfigFile writes configuration file to local file system.
+// The file is replaced atomically.
+// Lines not affected by changes are kept as they were read.
 func SaveConfigFile(c *ConfigFile, filename string) (err error) {
-	// Write configuration file by filename.
-	var f *os.File
-	if f, err = os.Create(filename); err != nil {
-		return err
+	if c.syntax != nil {
+		return base.WriteFileAtomic(filename, c.syntax.bytes())
 	}
 
 	equalSign := "="
@@ -101,8 +102,5 @@ func SaveConfigFile(c *ConfigFile, filename string) (err error) {
 		}
 	}
 
-	if _, err = buf.WriteTo(f); err != nil {
-		return err
-	}
-	return f.Close()
+	return base.WriteFileAtomic(filename, buf.Bytes())
 }
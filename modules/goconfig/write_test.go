@@ -0,0 +1,122 @@
+// Copyright 2013 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package goconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRoundTripData = `; Global options.
+name=gopm
+
+# Dependencies of project.
+[deps]
+  github.com/Unknwon/com   =   tag:v1.0
+  # Pinned for API compatibility.
+  github.com/codegangsta/cli	= commit:abc123
+
+[res]
+include = conf|templates
+`
+
+func Test_SaveConfigFile_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		data   string
+		modify func(c *ConfigFile)
+		expect string
+	}{
+		{
+			"unchanged",
+			testRoundTripData,
+			func(c *ConfigFile) {},
+			testRoundTripData,
+		},
+		{
+			"unchanged with CRLF line breaks",
+			"[deps]\r\na = 1\r\n\r\n[res]\r\nb = 2",
+			func(c *ConfigFile) {},
+			"[deps]\r\na = 1\r\n\r\n[res]\r\nb = 2",
+		},
+		{
+			"same value",
+			testRoundTripData,
+			func(c *ConfigFile) { c.SetValue("deps", "github.com/Unknwon/com", "tag:v1.0") },
+			testRoundTripData,
+		},
+		{
+			"change value",
+			testRoundTripData,
+			func(c *ConfigFile) { c.SetValue("deps", "github.com/Unknwon/com", "tag:v2.0") },
+			"; Global options.\nname=gopm\n\n# Dependencies of project.\n[deps]\n  github.com/Unknwon/com   =   tag:v2.0\n  # Pinned for API compatibility.\n  github.com/codegangsta/cli\t= commit:abc123\n\n[res]\ninclude = conf|templates\n",
+		},
+		{
+			"add key",
+			testRoundTripData,
+			func(c *ConfigFile) { c.SetValue("deps", "github.com/gpmgo/gopm", "") },
+			"; Global options.\nname=gopm\n\n# Dependencies of project.\n[deps]\n  github.com/Unknwon/com   =   tag:v1.0\n  # Pinned for API compatibility.\n  github.com/codegangsta/cli\t= commit:abc123\n  github.com/gpmgo/gopm = \n\n[res]\ninclude = conf|templates\n",
+		},
+		{
+			"add section",
+			testRoundTripData,
+			func(c *ConfigFile) { c.SetValue("target", "path", "github.com/gpmgo/gopm") },
+			testRoundTripData + "\n[target]\npath = github.com/gpmgo/gopm\n",
+		},
+		{
+			"delete key with comments",
+			testRoundTripData,
+			func(c *ConfigFile) { c.DeleteKey("deps", "github.com/codegangsta/cli") },
+			"; Global options.\nname=gopm\n\n# Dependencies of project.\n[deps]\n  github.com/Unknwon/com   =   tag:v1.0\n\n[res]\ninclude = conf|templates\n",
+		},
+		{
+			"delete section",
+			testRoundTripData,
+			func(c *ConfigFile) { c.DeleteSection("deps") },
+			"; Global options.\nname=gopm\n\n[res]\ninclude = conf|templates\n",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "goconfig-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range testCases {
+		fileName := filepath.Join(dir, "app.ini")
+		if err = ioutil.WriteFile(fileName, []byte(tc.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := LoadConfigFile(fileName)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		tc.modify(c)
+		if err = SaveConfigFile(c, fileName); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.expect {
+			t.Errorf("%s: expect\n%q\ngot\n%q", tc.desc, tc.expect, data)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package setting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/goconfig"
+)
+
+// ALIAS_SECTION is the section name of package short name aliases in gopmfile.
+const ALIAS_SECTION = "aliases"
+
+// Sources of package short names in order of precedence.
+const (
+	ALIAS_PROJECT = "project"
+	ALIAS_USER    = "user"
+	ALIAS_LIST    = "list"
+)
+
+// An Alias represents a package short name and import paths it maps to.
+type Alias struct {
+	Name        string
+	ImportPaths []string // More than one means the name is ambiguous.
+	Source      string
+}
+
+// IsAmbiguous returns true if short name maps to several import paths.
+func (a *Alias) IsAmbiguous() bool {
+	return len(a.ImportPaths) > 1
+}
+
+// parseAliases parses lines of 'name=import path' pairs and calls fn with each pair,
+// blank lines and comments start with '#' are ignored.
+func parseAliases(data []byte, fn func(name, importPath string)) error {
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		infos := strings.SplitN(line, "=", 2)
+		if len(infos) != 2 {
+			return fmt.Errorf("line %d: invalid package name pair: %s", i+1, line)
+		}
+		fn(strings.TrimSpace(infos[0]), strings.TrimSpace(infos[1]))
+	}
+	return nil
+}
+
+// ValidAlias returns error if given short name or import path is invalid.
+func ValidAlias(name, importPath string) error {
+	if len(name) == 0 || strings.ContainsAny(name, "/=@# \t") {
+		return fmt.Errorf("invalid package short name: %s", name)
+	}
+	if !base.IsValidRemotePath(importPath) {
+		return fmt.Errorf("invalid import path: %s", importPath)
+	}
+	return nil
+}
+
+func readUserAliases() (map[string]string, error) {
+	aliases := make(map[string]string)
+	data, err := ioutil.ReadFile(AliasesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return aliases, nil
+		}
+		return nil, fmt.Errorf("fail to load aliases.list: %v", err)
+	}
+	if err = parseAliases(data, func(name, importPath string) {
+		aliases[name] = importPath
+	}); err != nil {
+		return nil, fmt.Errorf("fail to parse aliases.list: %v", err)
+	}
+	return aliases, nil
+}
+
+// LoadUserAliases loads user-level aliases, which take precedence over package name lists.
+func LoadUserAliases() (err error) {
+	UserAliases, err = readUserAliases()
+	return err
+}
+
+// updateUserAliases reloads user-level aliases under lock,
+// applies change and saves them back in order of name.
+func updateUserAliases(fn func(map[string]string) error) error {
+	os.MkdirAll(path.Dir(AliasesFile), os.ModePerm)
+	lock, err := lockDataFile(AliasesFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	aliases, err := readUserAliases()
+	if err != nil {
+		return err
+	}
+	if err = fn(aliases); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := make([]byte, 0, len(names)*40)
+	for _, name := range names {
+		buf = append(buf, name+"="+aliases[name]+"\n"...)
+	}
+	if err = base.WriteFileAtomic(AliasesFile, buf); err != nil {
+		return fmt.Errorf("fail to save aliases.list: %v", err)
+	}
+	UserAliases = aliases
+	return nil
+}
+
+// SetUserAlias adds or replaces user-level alias.
+func SetUserAlias(name, importPath string) error {
+	return updateUserAliases(func(aliases map[string]string) error {
+		aliases[name] = importPath
+		return nil
+	})
+}
+
+// DeleteUserAlias deletes user-level alias.
+func DeleteUserAlias(name string) error {
+	return updateUserAliases(func(aliases map[string]string) error {
+		if _, ok := aliases[name]; !ok {
+			return fmt.Errorf("no user alias with given short name: %s", name)
+		}
+		delete(aliases, name)
+		return nil
+	})
+}
+
+// LoadProjectAliases sets project-level aliases from given gopmfile,
+// which take precedence over user-level ones.
+func LoadProjectAliases(gf *goconfig.ConfigFile) {
+	ProjectAliases = make(map[string]string)
+	if gf == nil {
+		return
+	}
+	for _, name := range gf.GetKeyList(ALIAS_SECTION) {
+		ProjectAliases[name] = gf.MustValue(ALIAS_SECTION, name)
+	}
+}
+
+// lookupAlias returns alias of given short name by precedence, or nil if not found.
+func lookupAlias(name string) *Alias {
+	if importPath, ok := ProjectAliases[name]; ok {
+		return &Alias{name, []string{importPath}, ALIAS_PROJECT}
+	}
+	if importPath, ok := UserAliases[name]; ok {
+		return &Alias{name, []string{importPath}, ALIAS_USER}
+	}
+	if importPaths, ok := PackageNameList[name]; ok {
+		return &Alias{name, importPaths, ALIAS_LIST}
+	}
+	return nil
+}
+
+// ListAliases returns effective aliases in order of name,
+// entries of package name lists are only included if withLists is true.
+func ListAliases(withLists bool) []*Alias {
+	names := make(map[string]bool)
+	for name := range ProjectAliases {
+		names[name] = true
+	}
+	for name := range UserAliases {
+		names[name] = true
+	}
+	if withLists {
+		for name := range PackageNameList {
+			names[name] = true
+		}
+	}
+
+	aliases := make([]*Alias, 0, len(names))
+	for name := range names {
+		aliases = append(aliases, lookupAlias(name))
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Name < aliases[j].Name
+	})
+	return aliases
+}
+
+// GetPkgFullPath attmpts to get full path by given package short name.
+func GetPkgFullPath(short string) (string, error) {
+	alias := lookupAlias(short)
+	if alias == nil {
+		return "", fmt.Errorf("no match package import path with given short name: %s", short)
+	}
+	if alias.IsAmbiguous() {
+		return "", fmt.Errorf("ambiguous package short name %s matches %s, use import path or 'gopm alias add' to choose one",
+			short, strings.Join(alias.ImportPaths, ", "))
+	}
+	return alias.ImportPaths[0], nil
+}
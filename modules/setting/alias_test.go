@@ -0,0 +1,137 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package setting
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/gpmgo/gopm/modules/goconfig"
+)
+
+// loadTestAliases sets aliases of all sources from given package name list,
+// user aliases and gopmfile.
+func loadTestAliases(t *testing.T, list string, user map[string]string, gopmfile string) {
+	tmpDir, err := ioutil.TempDir("", "gopm-alias-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	PkgNameListFile = path.Join(tmpDir, "pkgname.list")
+	if err = ioutil.WriteFile(PkgNameListFile, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = LoadPkgNameList(); err != nil {
+		t.Fatal(err)
+	}
+	UserAliases = user
+
+	gf, err := goconfig.LoadFromData([]byte(gopmfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	LoadProjectAliases(gf)
+}
+
+func Test_GetPkgFullPath(t *testing.T) {
+	loadTestAliases(t, `# Comment.
+cli = github.com/codegangsta/cli
+cli = github.com/urfave/cli
+cli = github.com/codegangsta/cli
+martini = github.com/go-martini/martini
+macaron = github.com/Unknwon/macaron
+`, map[string]string{
+		"martini": "github.com/codegangsta/martini",
+		"com":     "github.com/Unknwon/com",
+	}, `[aliases]
+macaron = gopkg.in/macaron.v1
+`)
+
+	testCases := []struct {
+		short  string
+		expect string
+		errStr string
+	}{
+		{"cli", "", "ambiguous package short name cli matches github.com/codegangsta/cli, github.com/urfave/cli"},
+		{"martini", "github.com/codegangsta/martini", ""},
+		{"com", "github.com/Unknwon/com", ""},
+		{"macaron", "gopkg.in/macaron.v1", ""},
+		{"none", "", "no match package import path"},
+	}
+	for _, tc := range testCases {
+		importPath, err := GetPkgFullPath(tc.short)
+		if len(tc.errStr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+				t.Errorf("%s: expect error contains '%s', got %v", tc.short, tc.errStr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.short, err)
+		} else if importPath != tc.expect {
+			t.Errorf("%s: expect '%s', got '%s'", tc.short, tc.expect, importPath)
+		}
+	}
+
+	// Only package name lists can be ambiguous, aliases override them.
+	expect := map[string]string{
+		"cli":     ALIAS_LIST,
+		"com":     ALIAS_USER,
+		"macaron": ALIAS_PROJECT,
+		"martini": ALIAS_USER,
+	}
+	aliases := ListAliases(true)
+	if len(aliases) != len(expect) {
+		t.Fatalf("expect %d aliases, got %d", len(expect), len(aliases))
+	}
+	for i, alias := range aliases {
+		if i > 0 && aliases[i-1].Name >= alias.Name {
+			t.Errorf("aliases are not in order of name: %s, %s", aliases[i-1].Name, alias.Name)
+		}
+		if alias.Source != expect[alias.Name] {
+			t.Errorf("%s: expect source '%s', got '%s'", alias.Name, expect[alias.Name], alias.Source)
+		}
+		if alias.IsAmbiguous() != (alias.Name == "cli") {
+			t.Errorf("%s: unexpected ambiguity: %v", alias.Name, alias.ImportPaths)
+		}
+	}
+	if aliases = ListAliases(false); len(aliases) != 3 {
+		t.Errorf("expect 3 aliases without lists, got %d", len(aliases))
+	}
+}
+
+func Test_ValidAlias(t *testing.T) {
+	testCases := []struct {
+		name       string
+		importPath string
+		isErr      bool
+	}{
+		{"cli", "github.com/codegangsta/cli", false},
+		{"", "github.com/codegangsta/cli", true},
+		{"a/b", "github.com/codegangsta/cli", true},
+		{"a b", "github.com/codegangsta/cli", true},
+		{"a@v1", "github.com/codegangsta/cli", true},
+		{"cli", "not a path", true},
+	}
+	for _, tc := range testCases {
+		if err := ValidAlias(tc.name, tc.importPath); (err != nil) != tc.isErr {
+			t.Errorf("%s=%s: expect error %v, got %v", tc.name, tc.importPath, tc.isErr, err)
+		}
+	}
+}
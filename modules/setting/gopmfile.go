@@ -0,0 +1,530 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package setting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/goconfig"
+)
+
+// Gopmfile formats.
+const (
+	FORMAT_INI  = "ini"
+	FORMAT_TOML = "toml"
+	FORMAT_JSON = "json"
+)
+
+// GopmfileFormat returns format of gopmfile by its name.
+func GopmfileFormat(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".toml":
+		return FORMAT_TOML
+	case ".json":
+		return FORMAT_JSON
+	}
+	return FORMAT_INI
+}
+
+// GopmfilePath returns path of gopmfile in given directory,
+// it checks '.gopmfile', 'gopm.toml' and 'gopm.json' in order
+// and falls back to '.gopmfile' if none of them exists.
+func GopmfilePath(dir string) string {
+	for _, name := range []string{GOPMFILE, GOPMFILE_TOML, GOPMFILE_JSON} {
+		if p := path.Join(dir, name); base.IsFile(p) {
+			return p
+		}
+	}
+	return path.Join(dir, GOPMFILE)
+}
+
+// newGopmfile returns an empty gopmfile representation.
+func newGopmfile() *goconfig.ConfigFile {
+	gf, _ := goconfig.LoadFromData([]byte(""))
+	return gf
+}
+
+// sectionKeys returns sections with their keys of gopmfile in order,
+// keys in DEFAULT section come first.
+func sectionKeys(gf *goconfig.ConfigFile) ([]string, map[string][]string) {
+	sections := make([]string, 0, 5)
+	keys := make(map[string][]string)
+	if list := gf.GetKeyList(goconfig.DEFAULT_SECTION); len(list) > 0 {
+		sections = append(sections, goconfig.DEFAULT_SECTION)
+		keys[goconfig.DEFAULT_SECTION] = list
+	}
+	for _, section := range gf.GetSectionList() {
+		if section == goconfig.DEFAULT_SECTION {
+			continue
+		}
+		sections = append(sections, section)
+		keys[section] = gf.GetKeyList(section)
+	}
+	return sections, keys
+}
+
+// rawValue returns value without expanding variables.
+func rawValue(gf *goconfig.ConfigFile, section, key string) string {
+	// Variables are expanded when read, not when convert.
+	sec, _ := gf.GetSection(section)
+	return sec[key]
+}
+
+// _________________
+//       TOML
+// _________________
+
+// isBareKey returns true if key can be written without quotes in TOML.
+func isBareKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteTOML returns TOML basic string of given string.
+func quoteTOML(s string) string {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7F {
+				fmt.Fprintf(buf, "\\u%04X", r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func tomlKey(key string) string {
+	if isBareKey(key) {
+		return key
+	}
+	return quoteTOML(key)
+}
+
+// marshalTOML returns gopmfile in TOML format.
+func marshalTOML(gf *goconfig.ConfigFile) []byte {
+	buf := new(bytes.Buffer)
+	sections, keys := sectionKeys(gf)
+	for i, section := range sections {
+		if section != goconfig.DEFAULT_SECTION {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("[" + tomlKey(section) + "]\n")
+		}
+		for _, key := range keys[section] {
+			buf.WriteString(tomlKey(key) + " = " + quoteTOML(rawValue(gf, section, key)) + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// tomlParser parses a subset of TOML used by gopmfile:
+// tables, bare or quoted keys, strings, numbers, booleans and
+// single-line arrays which are joined by '|'.
+type tomlParser struct {
+	fileName string
+	line     int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.fileName, p.line, fmt.Sprintf(format, args...))
+}
+
+// parseBasicString parses a basic string at beginning of s with TOML escapes,
+// and returns rest of s.
+func (p *tomlParser) parseBasicString(s string) (string, string, error) {
+	buf := new(bytes.Buffer)
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return buf.String(), s[i+1:], nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'b':
+				buf.WriteByte('\b')
+			case 't':
+				buf.WriteByte('\t')
+			case 'n':
+				buf.WriteByte('\n')
+			case 'f':
+				buf.WriteByte('\f')
+			case 'r':
+				buf.WriteByte('\r')
+			case '"', '\\':
+				buf.WriteByte(s[i])
+			case 'u', 'U':
+				size := 4
+				if s[i] == 'U' {
+					size = 8
+				}
+				if i+size >= len(s) {
+					return "", "", p.errorf("invalid escape sequence: %s", s[i-1:])
+				}
+				code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return "", "", p.errorf("invalid escape sequence: %s", s[i-1:i+1+size])
+				}
+				buf.WriteRune(rune(code))
+				i += size
+			default:
+				return "", "", p.errorf("invalid escape sequence: %s", s[i-1:i+1])
+			}
+		case c < 0x20 && c != '\t' || c == 0x7F:
+			return "", "", p.errorf("control character %U must be escaped", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", "", p.errorf("unterminated string")
+}
+
+// parseString parses a basic or literal string at beginning of s,
+// and returns rest of s. Literal string has no escapes.
+func (p *tomlParser) parseString(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "'") {
+		return p.parseBasicString(s)
+	}
+
+	end := strings.Index(s[1:], "'")
+	if end == -1 {
+		return "", "", p.errorf("unterminated string")
+	}
+	return s[1 : end+1], s[end+2:], nil
+}
+
+// parseKey parses a bare or quoted key at beginning of s,
+// and returns rest of s.
+func (p *tomlParser) parseKey(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		key, rest, err := p.parseString(s)
+		return key, strings.TrimSpace(rest), err
+	}
+
+	i := 0
+	for i < len(s) && isBareKey(s[i:i+1]) {
+		i++
+	}
+	if i == 0 {
+		return "", "", p.errorf("invalid key: %s", s)
+	}
+	return s[:i], strings.TrimSpace(s[i:]), nil
+}
+
+// parseValue parses a value at beginning of s, and returns rest of s.
+func (p *tomlParser) parseValue(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case len(s) == 0:
+		return "", "", p.errorf("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return "", "", p.errorf("multi-line string is not supported")
+	case s[0] == '"' || s[0] == '\'':
+		val, rest, err := p.parseString(s)
+		return val, strings.TrimSpace(rest), err
+	case s[0] == '[':
+		vals := make([]string, 0, 3)
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			if len(s) == 0 {
+				return "", "", p.errorf("unterminated array")
+			}
+			val, rest, err := p.parseValue(s)
+			if err != nil {
+				return "", "", err
+			}
+			vals = append(vals, val)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return "", "", p.errorf("expect ',' or ']' in array")
+			}
+			s = rest
+		}
+		return strings.Join(vals, "|"), strings.TrimSpace(s[1:]), nil
+	}
+
+	// Numbers, booleans and dates are kept as they are.
+	end := strings.IndexAny(s, ",]#")
+	if end == -1 {
+		end = len(s)
+	}
+	val := strings.TrimSpace(s[:end])
+	if len(val) == 0 {
+		return "", "", p.errorf("invalid value: %s", s)
+	}
+	return val, strings.TrimSpace(s[end:]), nil
+}
+
+// parse parses TOML data into gopmfile representation.
+func (p *tomlParser) parse(r io.Reader) (*goconfig.ConfigFile, error) {
+	gf := newGopmfile()
+	section := goconfig.DEFAULT_SECTION
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.line++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if strings.HasPrefix(line, "[[") {
+				return nil, p.errorf("array of tables is not supported")
+			}
+			name, rest, err := p.parseKey(line[1:])
+			if err != nil {
+				return nil, err
+			}
+			// Dotted table name is treated as sub-section.
+			for strings.HasPrefix(rest, ".") {
+				var sub string
+				if sub, rest, err = p.parseKey(rest[1:]); err != nil {
+					return nil, err
+				}
+				name += "." + sub
+			}
+			if !strings.HasPrefix(rest, "]") {
+				return nil, p.errorf("expect ']' after table name")
+			} else if rest = strings.TrimSpace(rest[1:]); len(rest) > 0 && rest[0] != '#' {
+				return nil, p.errorf("unexpected content after table: %s", rest)
+			}
+			section = name
+			gf.SetPosition(section, "", p.fileName, p.line)
+			continue
+		}
+
+		key, rest, err := p.parseKey(line)
+		if err != nil {
+			return nil, err
+		} else if !strings.HasPrefix(rest, "=") {
+			return nil, p.errorf("expect '=' after key %s", key)
+		}
+		val, rest, err := p.parseValue(rest[1:])
+		if err != nil {
+			return nil, err
+		} else if len(rest) > 0 && rest[0] != '#' {
+			return nil, p.errorf("unexpected content after value: %s", rest)
+		}
+		gf.SetValue(section, key, val)
+		gf.SetPosition(section, key, p.fileName, p.line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return gf, nil
+}
+
+// _________________
+//       JSON
+// _________________
+
+// marshalJSON returns gopmfile in JSON format,
+// sections and keys are kept in order.
+func marshalJSON(gf *goconfig.ConfigFile) []byte {
+	quote := func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("{")
+	sections, keys := sectionKeys(gf)
+	isFirst := true
+	writeSep := func(indent string) {
+		if !isFirst {
+			buf.WriteString(",")
+		}
+		isFirst = false
+		buf.WriteString("\n" + indent)
+	}
+
+	// Keys in DEFAULT section are top level values.
+	for _, section := range sections {
+		if section != goconfig.DEFAULT_SECTION {
+			continue
+		}
+		for _, key := range keys[section] {
+			writeSep("  ")
+			buf.WriteString(quote(key) + ": " + quote(rawValue(gf, section, key)))
+		}
+	}
+	for _, section := range sections {
+		if section == goconfig.DEFAULT_SECTION {
+			continue
+		}
+		writeSep("  ")
+		buf.WriteString(quote(section) + ": {")
+		isFirst = true
+		for _, key := range keys[section] {
+			writeSep("    ")
+			buf.WriteString(quote(key) + ": " + quote(rawValue(gf, section, key)))
+		}
+		if !isFirst {
+			buf.WriteString("\n  ")
+		}
+		buf.WriteString("}")
+		isFirst = false
+	}
+	buf.WriteString("\n}\n")
+	return buf.Bytes()
+}
+
+// jsonValue converts JSON value to gopmfile value,
+// arrays are joined by '|'.
+func jsonValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool, float64, json.Number:
+		return fmt.Sprint(v), true
+	case []interface{}:
+		vals := make([]string, len(v))
+		for i := range v {
+			val, ok := jsonValue(v[i])
+			if !ok {
+				return "", false
+			}
+			vals[i] = val
+		}
+		return strings.Join(vals, "|"), true
+	}
+	return "", false
+}
+
+// parseJSON parses JSON data into gopmfile representation,
+// order of sections and keys and their line numbers are kept.
+func parseJSON(fileName string, data []byte) (*goconfig.ConfigFile, error) {
+	gf := newGopmfile()
+	lineAt := func(offset int64) int {
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+	// walkObject calls fn with each key, its line number and raw value
+	// of JSON object in order, base is offset of object in data.
+	var walkObject func(base int64, fn func(string, int, int64, json.RawMessage) error) error
+	walkObject = func(base int64, fn func(string, int, int64, json.RawMessage) error) error {
+		dec := json.NewDecoder(bytes.NewReader(data[base:]))
+		if t, err := dec.Token(); err != nil {
+			return err
+		} else if t != json.Delim('{') {
+			return fmt.Errorf("expect JSON object")
+		}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			line := lineAt(base + dec.InputOffset())
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err != nil {
+				return err
+			}
+			offset := base + dec.InputOffset() - int64(len(raw))
+			if err = fn(t.(string), line, offset, raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := walkObject(0, func(name string, line int, offset int64, raw json.RawMessage) error {
+		// Object is a section, others are top level values.
+		if bytes.HasPrefix(raw, []byte("{")) {
+			gf.SetPosition(name, "", fileName, line)
+			return walkObject(offset, func(key string, line int, _ int64, raw json.RawMessage) error {
+				var v interface{}
+				json.Unmarshal(raw, &v)
+				val, ok := jsonValue(v)
+				if !ok {
+					return fmt.Errorf("invalid value of '%s' in section '%s'", key, name)
+				}
+				gf.SetValue(name, key, val)
+				gf.SetPosition(name, key, fileName, line)
+				return nil
+			})
+		}
+
+		var v interface{}
+		json.Unmarshal(raw, &v)
+		val, ok := jsonValue(v)
+		if !ok {
+			return fmt.Errorf("invalid value of '%s'", name)
+		}
+		gf.SetValue(goconfig.DEFAULT_SECTION, name, val)
+		gf.SetPosition(goconfig.DEFAULT_SECTION, name, fileName, line)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	return gf, nil
+}
+
+// loadGopmfile loads gopmfile in given format.
+func loadGopmfile(fileName, format string, data []byte) (*goconfig.ConfigFile, error) {
+	switch format {
+	case FORMAT_TOML:
+		return (&tomlParser{fileName: fileName}).parse(bytes.NewReader(data))
+	case FORMAT_JSON:
+		return parseJSON(fileName, data)
+	}
+	return goconfig.LoadFromFileData(fileName, data)
+}
+
+// MarshalGopmfile returns content of gopmfile in given format.
+func MarshalGopmfile(gf *goconfig.ConfigFile, format string) ([]byte, error) {
+	switch format {
+	case FORMAT_TOML:
+		return marshalTOML(gf), nil
+	case FORMAT_JSON:
+		return marshalJSON(gf), nil
+	}
+	return nil, fmt.Errorf("unsupported gopmfile format: %s", format)
+}
@@ -0,0 +1,202 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package setting
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gpmgo/gopm/modules/goconfig"
+)
+
+func Test_tomlParser_parseValue(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect string
+		isErr  bool
+	}{
+		{`"github.com/gpmgo/gopm"`, "github.com/gpmgo/gopm", false},
+		{`"tab\tnew\nline"`, "tab\tnew\nline", false},
+		{`"\b\f\r\"\\"`, "\b\f\r\"\\", false},
+		{`"\u00e9\U0001F600"`, "\u00e9\U0001F600", false},
+		{`'C:\Users\gopm'`, `C:\Users\gopm`, false},
+		{`'no "escapes" \n'`, `no "escapes" \n`, false},
+		{`["a", 'b', 1]`, "a|b|1", false},
+		{`true`, "true", false},
+		{`42 # comment`, "42", false},
+		{`"\a"`, "", true},     // Go escape, invalid in TOML.
+		{`"\x41"`, "", true},   // Go escape, invalid in TOML.
+		{`"\101"`, "", true},   // Go escape, invalid in TOML.
+		{`"\uD800"`, "", true}, // Surrogate is not a scalar value.
+		{`"\u12"`, "", true},
+		{"\"\x01\"", "", true},
+		{`"unterminated`, "", true},
+		{`'unterminated`, "", true},
+		{`["a", "b"`, "", true},
+		{`"""multi"""`, "", true},
+	}
+
+	for _, tc := range testCases {
+		p := &tomlParser{fileName: "gopm.toml"}
+		val, _, err := p.parseValue(tc.input)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%s: expect error, got '%s'", tc.input, val)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.input, err)
+		} else if val != tc.expect {
+			t.Errorf("%s: expect '%s', got '%s'", tc.input, tc.expect, val)
+		}
+	}
+}
+
+// gopmfileValues returns all values of gopmfile by 'section.key'.
+func gopmfileValues(gf *goconfig.ConfigFile) map[string]string {
+	values := make(map[string]string)
+	sections, keys := sectionKeys(gf)
+	for _, section := range sections {
+		for _, key := range keys[section] {
+			values[section+"."+key] = rawValue(gf, section, key)
+		}
+	}
+	return values
+}
+
+func Test_ConvertGopmfile(t *testing.T) {
+	gf, err := goconfig.LoadFromData([]byte(`[target]
+path = github.com/gpmgo/gopm
+
+[deps]
+github.com/codegangsta/cli = tag:v1.2.0
+"weird key" = value with "quotes" and \backslash
+tabs = a	b
+
+[res]
+include = conf|templates
+
+[build]
+ldflags = -X main.home=${HOME}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := gopmfileValues(gf)
+
+	for _, format := range []string{FORMAT_TOML, FORMAT_JSON} {
+		data, err := MarshalGopmfile(gf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		converted, err := loadGopmfile("gopm."+format, format, data)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, data)
+		}
+		if values := gopmfileValues(converted); !reflect.DeepEqual(values, expect) {
+			t.Errorf("%s: expect %v, got %v", format, expect, values)
+		}
+		if sections := converted.GetSectionList(); !reflect.DeepEqual(sections, gf.GetSectionList()) {
+			t.Errorf("%s: expect sections %v, got %v", format, gf.GetSectionList(), sections)
+		}
+	}
+}
+
+func Test_parseJSON(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		data   string
+		expect map[string]string
+		errStr string
+	}{
+		{
+			"sections, arrays and top level values",
+			`{"name": "gopm", "deps": {"a": "tag:v1", "b": null}, "res": {"include": ["conf", "public"]}, "build": {"cgo": true, "jobs": 4}}`,
+			map[string]string{
+				"DEFAULT.name": "gopm", "deps.a": "tag:v1", "deps.b": "",
+				"res.include": "conf|public", "build.cgo": "true", "build.jobs": "4",
+			},
+			"",
+		},
+		{"not an object", `["a"]`, nil, "expect JSON object"},
+		{"nested object in section", `{"deps": {"a": {"b": "c"}}}`, nil, "invalid value of 'a' in section 'deps'"},
+	}
+
+	for _, tc := range testCases {
+		gf, err := parseJSON("gopm.json", []byte(tc.data))
+		if len(tc.errStr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+				t.Errorf("%s: expect error contains '%s', got %v", tc.desc, tc.errStr, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: %v", tc.desc, err)
+			continue
+		}
+		if values := gopmfileValues(gf); !reflect.DeepEqual(values, tc.expect) {
+			t.Errorf("%s: expect %v, got %v", tc.desc, tc.expect, values)
+		}
+	}
+}
+
+func Test_loadGopmfile_Position(t *testing.T) {
+	testCases := []struct {
+		format string
+		data   string
+	}{
+		{FORMAT_TOML, `# Comment.
+name = "gopm"
+[deps]
+
+"github.com/a/b" = "tag:v1"
+c = "branch:master"
+`},
+		{FORMAT_INI, `; Comment.
+name = gopm
+[deps]
+
+github.com/a/b = tag:v1
+c = branch:master
+`},
+		{FORMAT_JSON, `{
+  "name": "gopm",
+  "deps": {
+
+    "github.com/a/b": "tag:v1",
+    "c":
+      "branch:master"
+  }
+}
+`},
+	}
+	// 'section.key' to expected line number, empty key is section header.
+	expect := map[string]int{"DEFAULT.name": 2, "deps.": 3, "deps.github.com/a/b": 5, "deps.c": 6}
+
+	for _, tc := range testCases {
+		fileName := "gopm." + tc.format
+		gf, err := loadGopmfile(fileName, tc.format, []byte(tc.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		for name, line := range expect {
+			infos := strings.SplitN(name, ".", 2)
+			if posName, posLine := gf.Position(infos[0], infos[1]); posName != fileName || posLine != line {
+				t.Errorf("%s: expect %s at %s:%d, got %s:%d", tc.format, name, fileName, line, posName, posLine)
+			}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package setting
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpmgo/gopm/modules/base"
+	"github.com/gpmgo/gopm/modules/goconfig"
+)
+
+// ROOT_PATH_SECTION is the section name of root path rules
+// in both gopm.ini and gopmfile.
+const ROOT_PATH_SECTION = "root_path"
+
+// A RootPathRule describes how to find project root path
+// of import paths start with Prefix.
+// Either first Segments path segments or first match of Pattern
+// is the root path, first submatch is used when pattern has one.
+type RootPathRule struct {
+	Prefix   string
+	Segments int
+	Pattern  *regexp.Regexp
+}
+
+// Match returns root path of given import path and true
+// if the rule applies to it.
+func (r *RootPathRule) Match(importPath string) (string, bool) {
+	if importPath != r.Prefix && !strings.HasPrefix(importPath, r.Prefix+"/") {
+		return "", false
+	}
+
+	if r.Pattern == nil {
+		subdirs := strings.Split(importPath, "/")
+		if len(subdirs) > r.Segments {
+			return strings.Join(subdirs[:r.Segments], "/"), true
+		}
+		return importPath, true
+	}
+
+	m := r.Pattern.FindStringSubmatchIndex(importPath)
+	if m == nil {
+		return "", false
+	}
+	start, end := m[0], m[1]
+	if len(m) > 3 && m[2] >= 0 {
+		start, end = m[2], m[3]
+	}
+	root := importPath[start:end]
+	if start != 0 || (root != importPath && !strings.HasPrefix(importPath, root+"/")) {
+		return "", false
+	}
+	return root, true
+}
+
+// ParseRootPathRule parses a rule from its prefix and value,
+// value is either number of path segments or a regular expression.
+func ParseRootPathRule(prefix, value string) (*RootPathRule, error) {
+	prefix = strings.Trim(prefix, "/")
+	if len(prefix) == 0 {
+		return nil, fmt.Errorf("empty root path prefix")
+	}
+	value = strings.TrimSpace(value)
+
+	if num, err := strconv.Atoi(value); err == nil {
+		if num < strings.Count(prefix, "/")+1 {
+			return nil, fmt.Errorf("number of segments %d is less than prefix '%s' has", num, prefix)
+		}
+		return &RootPathRule{Prefix: prefix, Segments: num}, nil
+	}
+
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root path pattern '%s': %v", value, err)
+	}
+	return &RootPathRule{Prefix: prefix, Pattern: pattern}, nil
+}
+
+// parseRootPathRules parses root path rules in given configuration,
+// rules are sorted by prefix from longest to shortest.
+func parseRootPathRules(cfg *goconfig.ConfigFile) ([]*RootPathRule, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	rules := make([]*RootPathRule, 0, 5)
+	for _, prefix := range cfg.GetKeyList(ROOT_PATH_SECTION) {
+		rule, err := ParseRootPathRule(prefix, cfg.MustValue(ROOT_PATH_SECTION, prefix))
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+	return rules, nil
+}
+
+// RootPathRules is the list of root path rules of gopmfile and gopm.ini
+// in order of precedence, RootPathPairs are checked after them.
+var RootPathRules []*RootPathRule
+
+// LoadRootPathRules sets root path rules from given gopmfile and gopm.ini,
+// rules in gopmfile take precedence over the ones in gopm.ini.
+func LoadRootPathRules(gf *goconfig.ConfigFile) error {
+	projectRules, err := parseRootPathRules(gf)
+	if err != nil {
+		return fmt.Errorf("fail to parse root path rules of gopmfile: %v", err)
+	}
+	userRules, err := parseRootPathRules(Cfg)
+	if err != nil {
+		return fmt.Errorf("fail to parse root path rules of config file: %v", err)
+	}
+	RootPathRules = append(projectRules, userRules...)
+	return nil
+}
+
+// MatchRootPath returns root path of given import path
+// by the first rule applies to it, then by RootPathPairs.
+func MatchRootPath(importPath string) (string, bool) {
+	for _, rule := range RootPathRules {
+		if root, ok := rule.Match(importPath); ok {
+			return root, true
+		}
+	}
+
+	host := importPath
+	if i := strings.Index(host, "/"); i > -1 {
+		host = host[:i]
+	}
+	if num, ok := RootPathPairs[host]; ok {
+		rule := &RootPathRule{Prefix: host, Segments: num}
+		return rule.Match(importPath)
+	}
+	return "", false
+}
+
+var (
+	// discoveredRootPaths contains root paths discovered by go-import meta tags.
+	discoveredRootPaths = make(map[string]bool)
+	// rootPathMisses contains import paths or hosts fail to discover root path
+	// with time of failure, they and their subpackages are not requested again
+	// until MetaCacheTTL passes.
+	rootPathMisses = make(map[string]time.Time)
+)
+
+// parseRootPaths parses list of root paths, one per line,
+// and failures of discovery in format of '!<import path or host> <unix time>'.
+func parseRootPaths(data []byte) (map[string]bool, map[string]time.Time) {
+	roots := make(map[string]bool)
+	misses := make(map[string]time.Time)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		} else if line[0] != '!' {
+			roots[line] = true
+			continue
+		}
+
+		infos := strings.Fields(line[1:])
+		if len(infos) != 2 {
+			continue
+		}
+		if sec, err := strconv.ParseInt(infos[1], 10, 64); err == nil {
+			misses[infos[0]] = time.Unix(sec, 0)
+		}
+	}
+	return roots, misses
+}
+
+// LoadRootPaths loads root paths discovered by previous runs.
+func LoadRootPaths() error {
+	data, err := ioutil.ReadFile(RootPathsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("fail to load rootpaths.list: %v", err)
+	}
+	discoveredRootPaths, rootPathMisses = parseRootPaths(data)
+	return nil
+}
+
+// DiscoveredRootPath returns discovered root path of given import path
+// and true if any.
+func DiscoveredRootPath(importPath string) (string, bool) {
+	for root := importPath; ; {
+		if discoveredRootPaths[root] {
+			return root, true
+		}
+		i := strings.LastIndex(root, "/")
+		if i < 0 {
+			return "", false
+		}
+		root = root[:i]
+	}
+}
+
+// IsRootPathMiss returns true if discovery of root path of given import path,
+// any of its parents or its host failed within MetaCacheTTL, unless RefreshMeta is set.
+func IsRootPathMiss(importPath string) bool {
+	if RefreshMeta {
+		return false
+	}
+	for name := importPath; ; {
+		if t, ok := rootPathMisses[name]; ok && time.Since(t) < MetaCacheTTL {
+			return true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+}
+
+// updateRootPaths reloads rootpaths.list under lock,
+// applies change and saves it back without expired failures.
+func updateRootPaths(fn func(roots map[string]bool, misses map[string]time.Time)) error {
+	os.MkdirAll(path.Dir(RootPathsFile), os.ModePerm)
+	lock, err := lockDataFile(RootPathsFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	data, err := ioutil.ReadFile(RootPathsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("fail to load rootpaths.list: %v", err)
+	}
+	roots, misses := parseRootPaths(data)
+	fn(roots, misses)
+
+	list := make([]string, 0, len(roots)+len(misses))
+	for r := range roots {
+		list = append(list, r)
+	}
+	for name, t := range misses {
+		if time.Since(t) < MetaCacheTTL {
+			list = append(list, fmt.Sprintf("!%s %d", name, t.Unix()))
+		} else {
+			delete(misses, name)
+		}
+	}
+	sort.Strings(list)
+	if err = base.WriteFileAtomic(RootPathsFile, []byte(strings.Join(list, "\n")+"\n")); err != nil {
+		return fmt.Errorf("fail to save rootpaths.list: %v", err)
+	}
+	discoveredRootPaths, rootPathMisses = roots, misses
+	return nil
+}
+
+// SaveRootPath merges discovered root path into latest rootpaths.list
+// on disk under lock and saves it.
+func SaveRootPath(root string) error {
+	discoveredRootPaths[root] = true
+	return updateRootPaths(func(roots map[string]bool, misses map[string]time.Time) {
+		roots[root] = true
+		for name := range misses {
+			if name == root || strings.HasPrefix(name, root+"/") || strings.HasPrefix(root, name+"/") {
+				delete(misses, name)
+			}
+		}
+	})
+}
+
+// SaveRootPathMiss records failure of discovery of root path of given import path
+// or host into rootpaths.list, so it is not requested again until MetaCacheTTL passes.
+func SaveRootPathMiss(importPath string) error {
+	rootPathMisses[importPath] = time.Now()
+	return updateRootPaths(func(roots map[string]bool, misses map[string]time.Time) {
+		misses[importPath] = time.Now()
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Unknwon
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package setting
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func Test_IsRootPathMiss(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "gopm-rootpath-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldFile := RootPathsFile
+	defer func() {
+		RootPathsFile = oldFile
+		discoveredRootPaths = make(map[string]bool)
+		rootPathMisses = make(map[string]time.Time)
+	}()
+	RootPathsFile = path.Join(tmpDir, "rootpaths.list")
+
+	// Unreachable host and import path without meta tag.
+	for _, name := range []string{"dead.example.com", "example.com/nometa"} {
+		if err = SaveRootPathMiss(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Failures are kept for next run.
+	if err = LoadRootPaths(); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		importPath string
+		expect     bool
+	}{
+		{"dead.example.com", true},
+		{"dead.example.com/a/b", true},
+		{"example.com/nometa", true},
+		{"example.com/nometa/sub", true},
+		{"example.com/other", false},
+		{"example.com", false},
+		{"dead.example.com.cn/a", false},
+	}
+	for _, tc := range testCases {
+		if IsRootPathMiss(tc.importPath) != tc.expect {
+			t.Errorf("%s: expect %v", tc.importPath, tc.expect)
+		}
+	}
+
+	RefreshMeta = true
+	if IsRootPathMiss("dead.example.com/a") {
+		t.Error("failure should be ignored when refresh meta")
+	}
+	RefreshMeta = false
+
+	// Discovered root path clears failures of its host and subpackages.
+	if err = SaveRootPath("dead.example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if IsRootPathMiss("dead.example.com/b") {
+		t.Error("failure of host should be cleared after discovery")
+	}
+	if root, ok := DiscoveredRootPath("dead.example.com/a/sub"); !ok || root != "dead.example.com/a" {
+		t.Errorf("expect discovered root path 'dead.example.com/a', got '%s'", root)
+	}
+}
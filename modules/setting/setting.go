@@ -17,9 +17,11 @@ package setting
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/gpmgo/gopm/modules/base"
 	"github.com/gpmgo/gopm/modules/goconfig"
@@ -35,16 +37,24 @@ type Option struct {
 }
 
 const (
-	VERSION     = 201602010
-	VENDOR      = ".vendor"
-	GOPMFILE    = ".gopmfile"
-	PKGNAMELIST = "pkgname.list"
-	VERINFO     = "data/VERSION.json"
+	VERSION       = 201602010
+	VENDOR        = ".vendor"
+	DIST          = "dist"
+	CHECKSUMS     = "SHA256SUMS"
+	GOPMFILE      = ".gopmfile"
+	GOPMFILE_TOML = "gopm.toml"
+	GOPMFILE_JSON = "gopm.json"
+	PKGNAMELIST   = "pkgname.list"
+	VERINFO       = "data/VERSION.json"
 )
 
 const (
 	URL_API_DOWNLOAD = "/api/v1/download"
 	URL_API_REVISION = "/api/v1/revision"
+	URL_API_SEARCH   = "/api/v1/search"
+
+	// Default remote list of package short names.
+	URL_PKGNAME_LIST = "https://raw.githubusercontent.com/gpmgo/docs/master/pkgname.list"
 )
 
 var (
@@ -52,14 +62,34 @@ var (
 	HomeDir          string
 	WorkDir          string // The path of gopm was executed.
 	PkgNameListFile  string
+	AliasesFile      string // User-level package short name aliases.
 	LocalNodesFile   string
+	RootPathsFile    string // Cache of root paths discovered by go-import meta tags.
+	MetaCacheFile    string // Cache of go-import meta tags.
+	HttpCacheDir     string // Cache of HTTP responses.
 	DefaultGopmfile  string
 	DefaultVendor    string
 	DefaultVendorSrc string
 	InstallRepoPath  string // The gopm local repository.
 	InstallGopath    string
 	HttpProxy        string
+	PkgNameListURLs         = []string{URL_PKGNAME_LIST}
 	RegistryURL      string = "https://gopm.io"
+	MetaCacheTTL            = 24 * time.Hour
+	RefreshMeta      bool   // Ignore cached go-import meta tags.
+	DiscoverRootPath bool   // Request go-import meta tags for unknown root paths, only when downloading.
+
+	// HTTP settings.
+	HttpDialTimeout     = 10 * time.Second
+	HttpRequestTimeout  = 20 * time.Second
+	HttpNoProxy         string // Comma-separated hosts to bypass proxy.
+	HttpCAFile          string // Extra CA certificates in PEM format.
+	HttpCertFile        string // Client certificate in PEM format.
+	HttpKeyFile         string // Key of client certificate, default is in certificate file.
+	HttpRetries         = 3    // Number of retries of failed requests.
+	HttpRetryWait       = time.Second
+	HttpRetryMaxWait    = 30 * time.Second
+	HttpMaxConnsPerHost int // Zero means no limit.
 
 	// System settings.
 	IsWindows        bool
@@ -74,10 +104,17 @@ var (
 	// Configuration settings.
 	ConfigFile      string
 	Cfg             *goconfig.ConfigFile
-	PackageNameList = make(map[string]string)
+	PackageNameList = make(map[string][]string)
+	UserAliases     = make(map[string]string)
+	ProjectAliases  = make(map[string]string)
 	LocalNodes      *goconfig.ConfigFile
+	// Changes of local nodes to be merged when save.
+	localNodeChanges = make(map[string]string)
 
-	// TODO: configurable.
+	// RootPathPairs maps host to number of path segments of project root path.
+	//
+	// Deprecated: use '[root_path]' section of gopm.ini or gopmfile instead,
+	// it is still checked after those rules.
 	RootPathPairs = map[string]int{
 		"github.com":      3,
 		"bitbucket.org":   3,
@@ -88,22 +125,37 @@ var (
 	CommonRes = []string{"views", "templates", "static", "public", "conf"}
 )
 
-// LoadGopmfile loads and returns given gopmfile.
+// LoadGopmfile loads and returns given gopmfile,
+// format is detected by file name.
 func LoadGopmfile(fileName string) (*goconfig.ConfigFile, error) {
 	if !base.IsFile(fileName) {
 		return goconfig.LoadFromData([]byte(""))
 	}
 
-	gf, err := goconfig.LoadConfigFile(fileName)
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Fail to load gopmfile: %v", err)
+	}
+	gf, err := loadGopmfile(fileName, GopmfileFormat(fileName), data)
 	if err != nil {
 		return nil, fmt.Errorf("Fail to load gopmfile: %v", err)
 	}
 	return gf, nil
 }
 
-// SaveGopmfile saves gopmfile to given path.
+// SaveGopmfile saves gopmfile to given path,
+// format is detected by file name.
 func SaveGopmfile(gf *goconfig.ConfigFile, fileName string) error {
-	if err := goconfig.SaveConfigFile(gf, fileName); err != nil {
+	var err error
+	if format := GopmfileFormat(fileName); format == FORMAT_INI {
+		err = goconfig.SaveConfigFile(gf, fileName)
+	} else {
+		var data []byte
+		if data, err = MarshalGopmfile(gf, format); err == nil {
+			err = base.WriteFileAtomic(fileName, data)
+		}
+	}
+	if err != nil {
 		return fmt.Errorf("Fail to save gopmfile: %v", err)
 	}
 	return nil
@@ -113,9 +165,11 @@ func SaveGopmfile(gf *goconfig.ConfigFile, fileName string) error {
 func LoadConfig() (err error) {
 	if !base.IsExist(ConfigFile) {
 		os.MkdirAll(path.Dir(ConfigFile), os.ModePerm)
-		if _, err = os.Create(ConfigFile); err != nil {
+		f, err := os.OpenFile(ConfigFile, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
 			return fmt.Errorf("fail to create config file: %v", err)
 		}
+		f.Close()
 	}
 
 	Cfg, err = goconfig.LoadConfigFile(ConfigFile)
@@ -124,13 +178,121 @@ func LoadConfig() (err error) {
 	}
 
 	HttpProxy = Cfg.MustValue("settings", "HTTP_PROXY")
+	HttpNoProxy = Cfg.MustValue("settings", "NO_PROXY", os.Getenv("NO_PROXY"))
+	if len(HttpNoProxy) == 0 {
+		HttpNoProxy = os.Getenv("no_proxy")
+	}
+	HttpCAFile = Cfg.MustValue("settings", "HTTP_CA_FILE")
+	HttpCertFile = Cfg.MustValue("settings", "HTTP_CERT_FILE")
+	HttpKeyFile = Cfg.MustValue("settings", "HTTP_KEY_FILE")
+	if HttpDialTimeout, err = durationValue("HTTP_DIAL_TIMEOUT", HttpDialTimeout); err != nil {
+		return err
+	}
+	if HttpRequestTimeout, err = durationValue("HTTP_REQUEST_TIMEOUT", HttpRequestTimeout); err != nil {
+		return err
+	}
+	if MetaCacheTTL, err = durationValue("META_CACHE_TTL", MetaCacheTTL); err != nil {
+		return err
+	}
+
+	HttpRetries = Cfg.MustInt("settings", "HTTP_RETRIES", HttpRetries)
+	if HttpRetryWait, err = durationValue("HTTP_RETRY_WAIT", HttpRetryWait); err != nil {
+		return err
+	}
+	if HttpRetryMaxWait, err = durationValue("HTTP_RETRY_MAX_WAIT", HttpRetryMaxWait); err != nil {
+		return err
+	}
+	HttpMaxConnsPerHost = Cfg.MustInt("settings", "HTTP_MAX_CONNS_PER_HOST", HttpMaxConnsPerHost)
+
+	PkgNameListURLs = nil
+	for _, u := range strings.Split(Cfg.MustValue("settings", "PKGNAME_LIST_URLS", URL_PKGNAME_LIST), ",") {
+		if u = strings.TrimSpace(u); len(u) > 0 {
+			PkgNameListURLs = append(PkgNameListURLs, u)
+		}
+	}
+	return nil
+}
+
+// durationValue returns duration value of given key in [settings] section
+// of configuration, or default value if it is not set.
+func durationValue(key string, defaultVal time.Duration) (time.Duration, error) {
+	val := Cfg.MustValue("settings", key)
+	if len(val) == 0 {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return defaultVal, fmt.Errorf("fail to parse %s: %v", key, err)
+	}
+	return d, nil
+}
+
+// lockDataFile acquires advisory lock for read-modify-write cycle
+// of given shared data file, so concurrent gopm processes
+// do not clobber each other's changes.
+func lockDataFile(fileName string) (*base.FileLock, error) {
+	lock, err := base.LockFile(fileName + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("fail to lock %s: %v", path.Base(fileName), err)
+	}
+	return lock, nil
+}
+
+// LockRepo acquires lock of the whole local repository.
+// Operations on single package hold shared lock,
+// operations on the whole repository like clean hold exclusive lock.
+func LockRepo(exclusive bool) (*base.FileLock, error) {
+	name := path.Join(HomeDir, ".gopm/locks/repos.lock")
+	var lock *base.FileLock
+	var err error
+	if exclusive {
+		lock, err = base.LockFile(name)
+	} else {
+		lock, err = base.RLockFile(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to lock local repository: %v", err)
+	}
+	return lock, nil
+}
+
+// LockPackage acquires exclusive lock of package with given install path
+// in local repository.
+func LockPackage(installPath string) (*base.FileLock, error) {
+	name := strings.TrimPrefix(strings.TrimPrefix(installPath, InstallRepoPath), "/")
+	lock, err := base.LockFile(path.Join(HomeDir, ".gopm/locks/pkgs", url.QueryEscape(name)+".lock"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to lock package(%s): %v", name, err)
+	}
+	return lock, nil
+}
+
+// updateConfig reloads gopm configuration under lock,
+// applies change and saves it back.
+func updateConfig(fn func(*goconfig.ConfigFile)) error {
+	lock, err := lockDataFile(ConfigFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	cfg, err := goconfig.LoadConfigFile(ConfigFile)
+	if err != nil {
+		return err
+	}
+	fn(cfg)
+	if err = goconfig.SaveConfigFile(cfg, ConfigFile); err != nil {
+		return err
+	}
+	Cfg = cfg
 	return nil
 }
 
 // SetConfigValue sets and saves gopm configuration.
 func SetConfigValue(section, key, val string) error {
-	Cfg.SetValue(section, key, val)
-	if err := goconfig.SaveConfigFile(Cfg, ConfigFile); err != nil {
+	if err := updateConfig(func(cfg *goconfig.ConfigFile) {
+		cfg.SetValue(section, key, val)
+	}); err != nil {
 		return fmt.Errorf("fail to set config value(%s:%s=%s): %v", section, key, val, err)
 	}
 	return nil
@@ -138,14 +300,16 @@ func SetConfigValue(section, key, val string) error {
 
 // DeleteConfigOption deletes and saves gopm configuration.
 func DeleteConfigOption(section, key string) error {
-	Cfg.DeleteKey(section, key)
-	if err := goconfig.SaveConfigFile(Cfg, ConfigFile); err != nil {
+	if err := updateConfig(func(cfg *goconfig.ConfigFile) {
+		cfg.DeleteKey(section, key)
+	}); err != nil {
 		return fmt.Errorf("fail to delete config key(%s:%s): %v", section, key, err)
 	}
 	return nil
 }
 
-// LoadPkgNameList loads package name pairs.
+// LoadPkgNameList loads package name pairs, a short name may map to
+// several import paths when lists from different sources conflict.
 func LoadPkgNameList() error {
 	if !base.IsFile(PkgNameListFile) {
 		return nil
@@ -156,34 +320,24 @@ func LoadPkgNameList() error {
 		return fmt.Errorf("fail to load package name list: %v", err)
 	}
 
-	pkgs := strings.Split(string(data), "\n")
-	for i, line := range pkgs {
-		infos := strings.Split(line, "=")
-		if len(infos) != 2 {
-			// Last item might be empty line.
-			if i == len(pkgs)-1 {
-				continue
-			}
-			return fmt.Errorf("fail to parse package name: %v", line)
+	PackageNameList = make(map[string][]string)
+	if err = parseAliases(data, func(name, importPath string) {
+		if !base.IsSliceContainsStr(PackageNameList[name], importPath) {
+			PackageNameList[name] = append(PackageNameList[name], importPath)
 		}
-		PackageNameList[strings.TrimSpace(infos[0])] = strings.TrimSpace(infos[1])
+	}); err != nil {
+		return fmt.Errorf("fail to parse package name list: %v", err)
 	}
 	return nil
 }
 
-// GetPkgFullPath attmpts to get full path by given package short name.
-func GetPkgFullPath(short string) (string, error) {
-	name, ok := PackageNameList[short]
-	if !ok {
-		return "", fmt.Errorf("no match package import path with given short name: %s", short)
-	}
-	return name, nil
-}
-
 func LoadLocalNodes() (err error) {
 	if !base.IsFile(LocalNodesFile) {
 		os.MkdirAll(path.Dir(LocalNodesFile), os.ModePerm)
-		os.Create(LocalNodesFile)
+		// Do not truncate file created by other process in the meantime.
+		if f, err := os.OpenFile(LocalNodesFile, os.O_WRONLY|os.O_CREATE, 0644); err == nil {
+			f.Close()
+		}
 	}
 
 	LocalNodes, err = goconfig.LoadConfigFile(LocalNodesFile)
@@ -193,9 +347,51 @@ func LoadLocalNodes() (err error) {
 	return nil
 }
 
+// SetLocalNode records revision of given package in local nodes,
+// call SaveLocalNodes to save changes.
+func SetLocalNode(rootPath, revision string) {
+	LocalNodes.SetValue(rootPath, "value", revision)
+	localNodeChanges[rootPath] = revision
+}
+
+// SaveLocalNodes merges changes into latest localnodes.list on disk
+// under lock and saves it.
 func SaveLocalNodes() error {
-	if err := goconfig.SaveConfigFile(LocalNodes, LocalNodesFile); err != nil {
+	if len(localNodeChanges) == 0 {
+		return nil
+	}
+
+	lock, err := lockDataFile(LocalNodesFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	nodes, err := goconfig.LoadConfigFile(LocalNodesFile)
+	if err != nil {
+		return fmt.Errorf("fail to load localnodes.list: %v", err)
+	}
+	for rootPath, revision := range localNodeChanges {
+		nodes.SetValue(rootPath, "value", revision)
+	}
+	if err = goconfig.SaveConfigFile(nodes, LocalNodesFile); err != nil {
 		return fmt.Errorf("fail to save localnodes.list: %v", err)
 	}
+	LocalNodes = nodes
+	localNodeChanges = make(map[string]string)
+	return nil
+}
+
+// ClearLocalNodes deletes localnodes.list under lock.
+func ClearLocalNodes() error {
+	lock, err := lockDataFile(LocalNodesFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err = os.Remove(LocalNodesFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("fail to delete localnodes.list: %v", err)
+	}
 	return nil
 }